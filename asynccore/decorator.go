@@ -0,0 +1,88 @@
+package asynccore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/legenove/cocore"
+	"github.com/legenove/nano-server-sdk/servers"
+	"github.com/legenove/nano-server-sdk/stacktrace"
+	"go.uber.org/zap"
+)
+
+// Handler 任务处理函数, 超时后 ctx 被取消, 需要及时返回
+type Handler func(ctx context.Context, task *Task) error
+
+type HandlerDecorator func(name string, handler Handler) Handler
+
+// LoggerRecoveryHandler 恢复 panic 并写入异步任务日志
+// 定义的 ServerError 记为 warn, 其他错误记为 error
+func LoggerRecoveryHandler(name string, handler Handler) Handler {
+	return func(ctx context.Context, task *Task) (err error) {
+		start := time.Now()
+		defer func() {
+			var reason interface{}
+			var errorCode interface{}
+			var stackFields []zap.Field
+			level := cocore.LOG_LEVEL_ERROR
+			if r := recover(); r != nil {
+				switch r.(type) {
+				case *servers.ServerError:
+					_err := r.(*servers.ServerError)
+					reason = _err.Error()
+					errorCode = _err.Code
+					level = cocore.LOG_LEVEL_WARN
+					err = _err
+				case error:
+					stackFields = stacktrace.Capture(1).Fields()
+					reason = fmt.Sprintf("[Recovery] panic recovered: %s", r)
+					errorCode = "10001"
+					err = r.(error)
+				default:
+					reason = fmt.Sprint(r)
+					errorCode = "10000"
+					err = fmt.Errorf("%v", r)
+				}
+			} else if err != nil {
+				reason = err.Error()
+				if _err, ok := err.(*servers.ServerError); ok {
+					errorCode = _err.Code
+					level = cocore.LOG_LEVEL_WARN
+				}
+			}
+			asyncLog(ctx, task, level, errorCode, reason, time.Since(start), stackFields...)
+		}()
+		return handler(ctx, task)
+	}
+}
+
+func asyncLog(ctx context.Context, task *Task, level string, errorCode, reason interface{}, duration time.Duration, extra ...zap.Field) {
+	logger, err := servers.LogInstance(servers.LogDirAsync)
+	if err != nil {
+		return
+	}
+	logger = servers.AddRequestLog(logger, ctx)
+	fields := []zap.Field{
+		zap.String("log_type", servers.LOG_TYPE_ASYNC),
+		zap.String("event", servers.LogEventAsync),
+		zap.Namespace("properties"),
+		zap.String("taskId", task.ID),
+		zap.String("taskName", task.Name),
+		zap.String("queue", task.Queue),
+		zap.Int("retried", task.Retried),
+		zap.Duration("time", duration),
+	}
+	if reason != nil {
+		fields = append(fields, zap.Reflect("error_code", errorCode), zap.Reflect("reason", reason))
+		fields = append(fields, extra...)
+	}
+	switch {
+	case reason == nil:
+		logger.Info("async", fields...)
+	case level == cocore.LOG_LEVEL_WARN:
+		logger.Warn("async", fields...)
+	default:
+		logger.Error("async", fields...)
+	}
+}
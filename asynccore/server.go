@@ -0,0 +1,353 @@
+package asynccore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/legenove/cocore"
+	"github.com/legenove/nano-server-sdk/redis_client"
+	"github.com/legenove/nano-server-sdk/servers"
+	"google.golang.org/grpc/metadata"
+)
+
+var handlerMapper = map[string]Handler{}
+var mu sync.Mutex
+
+// RegisterHandler 注册任务处理函数, 默认使用 LoggerRecoveryHandler 装饰
+func RegisterHandler(name string, h Handler, decorators ...HandlerDecorator) {
+	if len(decorators) == 0 {
+		decorators = []HandlerDecorator{LoggerRecoveryHandler}
+	}
+	for _, d := range decorators {
+		h = d(name, h)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	handlerMapper[name] = h
+}
+
+func getHandler(name string) (Handler, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	h, ok := handlerMapper[name]
+	return h, ok
+}
+
+// 到期的延迟任务移入待执行队列
+var moveDueScript = redis.NewScript(`
+local tasks = redis.call("zrangebyscore", KEYS[1], "-inf", ARGV[1], "limit", 0, ARGV[2])
+for _, task in ipairs(tasks) do
+	redis.call("zrem", KEYS[1], task)
+	redis.call("lpush", KEYS[2], task)
+end
+return #tasks`)
+
+// 心跳过期的副本处理中的任务放回队列, KEYS: heartbeat, processing, ready, workers, ARGV: identity
+var recoverScript = redis.NewScript(`
+if redis.call("exists", KEYS[1]) == 1 then
+	return 0
+end
+local n = 0
+while redis.call("rpoplpush", KEYS[2], KEYS[3]) do
+	n = n + 1
+end
+redis.call("srem", KEYS[4], ARGV[1])
+return n`)
+
+// 多个队列时没有任务的等待时间
+var idleInterval = 100 * time.Millisecond
+
+type serverOptions struct {
+	queues      []string
+	concurrency int
+	identity    string
+	idleTimeout time.Duration
+}
+
+type ServerOption func(*serverOptions)
+
+// WithQueues 消费的队列, 排在前面的优先, 默认 default
+func WithQueues(queues ...string) ServerOption {
+	return func(o *serverOptions) {
+		o.queues = queues
+	}
+}
+
+// WithConcurrency worker 数量, 默认 10
+func WithConcurrency(n int) ServerOption {
+	return func(o *serverOptions) {
+		o.concurrency = n
+	}
+}
+
+// WithIdentity 当前副本的身份, 用于区分处理中的任务列表, 默认 hostname
+// 重启后保持不变时, 启动即可恢复上次退出时未完成的任务, 否则等待其他副本在心跳过期后恢复
+func WithIdentity(identity string) ServerOption {
+	return func(o *serverOptions) {
+		o.identity = identity
+	}
+}
+
+// WithIdleTimeout 副本心跳的过期时间, 默认 1m
+// 副本退出后没有以相同身份重启时, 其他副本在心跳过期后将其处理中的任务放回队列
+func WithIdleTimeout(d time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.idleTimeout = d
+	}
+}
+
+type Server struct {
+	router string
+	opt    serverOptions
+}
+
+func NewServer(routerName string, opts ...ServerOption) *Server {
+	host, _ := os.Hostname()
+	opt := serverOptions{queues: []string{"default"}, concurrency: 10, identity: host, idleTimeout: time.Minute}
+	for _, o := range opts {
+		o(&opt)
+	}
+	return &Server{router: routerName, opt: opt}
+}
+
+// Run 启动 worker 池, 阻塞直到 ctx 结束且执行中的任务完成
+// 取出的任务先移入处理中列表, 执行结束后删除, 启动时将上次未完成的任务放回队列
+// 运行期间定期写入心跳, 并将心跳过期的其他副本处理中的任务放回队列
+// 每次操作都从 redis_client 获取客户端, 配置热更新后使用新的客户端
+func (s *Server) Run(ctx context.Context) error {
+	if s.opt.idleTimeout <= 0 {
+		return fmt.Errorf("asynccore: idle timeout must be positive, got %s", s.opt.idleTimeout)
+	}
+	client, err := redis_client.GetRedisClient(s.router)
+	if err != nil {
+		return err
+	}
+	if err := s.requeueProcessing(client); err != nil {
+		return err
+	}
+	if err := s.heartbeat(client); err != nil {
+		return err
+	}
+	go s.moveDue(ctx)
+	// 心跳持续到执行中的任务完成, 避免任务被其他副本放回队列
+	hctx, stop := context.WithCancel(context.Background())
+	hdone := make(chan struct{})
+	go func() {
+		defer close(hdone)
+		s.recoverIdle(hctx)
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < s.opt.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.work(ctx)
+		}()
+	}
+	wg.Wait()
+	stop()
+	<-hdone
+	s.unregister()
+	return nil
+}
+
+// requeueProcessing 将处理中列表中的任务放回队列
+func (s *Server) requeueProcessing(client *redis.Client) error {
+	for _, queue := range s.opt.queues {
+		for {
+			err := client.RPopLPush(processingKey(queue, s.opt.identity), readyKey(queue)).Err()
+			if err == redis.Nil {
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// heartbeat 登记当前副本并刷新心跳
+func (s *Server) heartbeat(client *redis.Client) error {
+	pipe := client.Pipeline()
+	for _, queue := range s.opt.queues {
+		pipe.SAdd(workersKey(queue), s.opt.identity)
+		pipe.Set(heartbeatKey(queue, s.opt.identity), time.Now().Unix(), s.opt.idleTimeout)
+	}
+	_, err := pipe.Exec()
+	return err
+}
+
+// unregister 退出时处理中的任务都已完成, 删除心跳并注销
+func (s *Server) unregister() {
+	client, err := redis_client.GetRedisClient(s.router)
+	if err != nil {
+		return
+	}
+	for _, queue := range s.opt.queues {
+		client.Del(heartbeatKey(queue, s.opt.identity))
+		client.SRem(workersKey(queue), s.opt.identity)
+	}
+}
+
+// recoverIdle 定期刷新心跳, 将心跳过期的副本处理中的任务放回队列
+func (s *Server) recoverIdle(ctx context.Context) {
+	ticker := time.NewTicker(s.opt.idleTimeout / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		client, err := redis_client.GetRedisClient(s.router)
+		if err != nil {
+			continue
+		}
+		s.heartbeat(client)
+		for _, queue := range s.opt.queues {
+			identities, err := client.SMembers(workersKey(queue)).Result()
+			if err != nil {
+				continue
+			}
+			for _, identity := range identities {
+				if identity == s.opt.identity {
+					continue
+				}
+				keys := []string{heartbeatKey(queue, identity), processingKey(queue, identity), readyKey(queue), workersKey(queue)}
+				recoverScript.Run(client, keys, identity)
+			}
+		}
+	}
+}
+
+func (s *Server) moveDue(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		client, err := redis_client.GetRedisClient(s.router)
+		if err != nil {
+			continue
+		}
+		now := float64(time.Now().UnixNano()) / float64(time.Second)
+		for _, queue := range s.opt.queues {
+			moveDueScript.Run(client, []string{delayedKey(queue), readyKey(queue)}, now, 100)
+		}
+	}
+}
+
+func (s *Server) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		client, err := redis_client.GetRedisClient(s.router)
+		if err != nil {
+			time.Sleep(time.Second)
+			continue
+		}
+		queue, data, err := s.take(client)
+		if err != nil {
+			if err != redis.Nil {
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+		var task Task
+		if err := jsoniter.Unmarshal([]byte(data), &task); err != nil {
+			asyncLog(ctx, &task, "", "10000", "invalid task: "+err.Error(), 0)
+		} else {
+			s.execute(&task)
+		}
+		if client, err := redis_client.GetRedisClient(s.router); err == nil {
+			client.LRem(processingKey(queue, s.opt.identity), 1, data)
+		}
+	}
+}
+
+// take 按队列顺序取出一个任务并移入处理中列表, 没有任务时返回 redis.Nil
+func (s *Server) take(client *redis.Client) (string, string, error) {
+	for _, queue := range s.opt.queues {
+		data, err := client.RPopLPush(readyKey(queue), processingKey(queue, s.opt.identity)).Result()
+		if err != redis.Nil {
+			return queue, data, err
+		}
+	}
+	if len(s.opt.queues) > 1 {
+		time.Sleep(idleInterval)
+		return "", "", redis.Nil
+	}
+	queue := s.opt.queues[0]
+	data, err := client.BRPopLPush(readyKey(queue), processingKey(queue, s.opt.identity), time.Second).Result()
+	return queue, data, err
+}
+
+// requestCtx 为任务创建请求上下文, 请求 id 沿用投递方的请求 id
+func requestCtx(ctx context.Context, task *Task) context.Context {
+	md := metadata.Pairs(
+		servers.SERVER_INCOME_REQUEST_ID, task.RequestId,
+		servers.SERVER_INCOME_SERVER_NAME, task.ServerName,
+		servers.SERVER_INCOME_SERVER_GROUP, task.ServerGroup,
+	)
+	ctx = metadata.NewIncomingContext(ctx, md)
+	ctx = servers.AppendToRequestCtx(ctx, servers.SERVER_REQUEST_TYPE, servers.GetServerTypeValue(servers.REQUEST_TYPE_ASYNC))
+	return servers.InitContext(ctx, task.Name, task)
+}
+
+// execute 执行任务, 不随服务退出中断, handler 返回后才会重新投递失败的任务
+func (s *Server) execute(task *Task) {
+	ctx := requestCtx(context.Background(), task)
+	h, ok := getHandler(task.Name)
+	if !ok {
+		asyncLog(ctx, task, "", "10000", "task handler not registered", 0)
+		return
+	}
+	err := run(ctx, h, task)
+	if err == nil {
+		return
+	}
+	if task.Retried >= task.MaxRetry {
+		asyncLog(ctx, task, "", "10000", "task failed after max retry: "+err.Error(), 0)
+		return
+	}
+	task.Retried++
+	// 退避: 1s, 4s, 9s ...
+	backoff := time.Duration(task.Retried*task.Retried) * time.Second
+	client, perr := redis_client.GetRedisClient(s.router)
+	if perr == nil {
+		perr = push(client, task, backoff)
+	}
+	if perr != nil {
+		asyncLog(ctx, task, "", "10000", "task retry enqueue failed: "+perr.Error(), 0)
+		return
+	}
+	asyncLog(ctx, task, cocore.LOG_LEVEL_WARN, "10000", fmt.Sprintf("task retry after %s: %s", backoff, err.Error()), 0)
+}
+
+// run 在超时时间内执行任务, 超时后取消 ctx 并等待 handler 返回, 任务按失败处理
+// handler 需要响应 ctx 的取消, 否则超时后仍会占用 worker 直到返回
+func run(ctx context.Context, h Handler, task *Task) error {
+	timeout := task.GetTimeout()
+	if timeout <= 0 {
+		return h(ctx, task)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := h(ctx, task)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("task timeout after %s: %s", timeout, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,155 @@
+package asynccore
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/legenove/nano-server-sdk/redis_client"
+	"github.com/legenove/nano-server-sdk/redis_client/redistest"
+)
+
+// 启动时将上次退出时处理中的任务放回队列, 执行完成后从处理中列表删除
+func TestServerRecoverProcessing(t *testing.T) {
+	mr := redistest.Start(t, "async_recover_redis")
+	client, _ := redis_client.GetRedisClient("async_recover_redis")
+	data, _ := jsoniter.Marshal(&Task{ID: "1", Name: "recover_task", Queue: "recover", Codec: CodecJSON})
+	if err := client.LPush(processingKey("recover", "host"), data).Err(); err != nil {
+		t.Fatal(err)
+	}
+	executed := make(chan string, 1)
+	RegisterHandler("recover_task", func(ctx context.Context, task *Task) error {
+		executed <- task.ID
+		return nil
+	})
+
+	s := NewServer("async_recover_redis", WithQueues("recover"), WithConcurrency(1), WithIdentity("host"))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	select {
+	case id := <-executed:
+		if id != "1" {
+			t.Errorf("unexpected task %s", id)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("processing task should be recovered")
+	}
+	deadline := time.Now().Add(time.Second)
+	for mr.Exists(processingKey("recover", "host")) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if mr.Exists(processingKey("recover", "host")) {
+		t.Error("finished task should be removed from the processing list")
+	}
+}
+
+// 心跳过期的副本处理中的任务由其他副本放回队列, 心跳有效的副本不受影响
+func TestServerRecoverIdle(t *testing.T) {
+	mr := redistest.Start(t, "async_idle_redis")
+	client, _ := redis_client.GetRedisClient("async_idle_redis")
+	for _, identity := range []string{"dead", "busy"} {
+		data, _ := jsoniter.Marshal(&Task{ID: identity, Name: "idle_task", Queue: "idle", Codec: CodecJSON})
+		client.LPush(processingKey("idle", identity), data)
+		client.SAdd(workersKey("idle"), identity)
+	}
+	client.Set(heartbeatKey("idle", "busy"), 1, time.Minute)
+	executed := make(chan string, 2)
+	RegisterHandler("idle_task", func(ctx context.Context, task *Task) error {
+		executed <- task.ID
+		return nil
+	})
+
+	s := NewServer("async_idle_redis", WithQueues("idle"), WithConcurrency(1),
+		WithIdentity("live"), WithIdleTimeout(60*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(ctx)
+	}()
+	select {
+	case id := <-executed:
+		if id != "dead" {
+			t.Errorf("unexpected task %s", id)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("task of the dead identity should be recovered")
+	}
+	select {
+	case id := <-executed:
+		t.Errorf("task of a live identity should not be recovered: %s", id)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if ok, _ := mr.SIsMember(workersKey("idle"), "dead"); ok {
+		t.Error("dead identity should be removed from workers")
+	}
+	cancel()
+	<-done
+	if mr.Exists(heartbeatKey("idle", "live")) {
+		t.Error("heartbeat should be removed after Run returns")
+	}
+}
+
+func delayedTasks(t *testing.T, queue string) []Task {
+	t.Helper()
+	client, _ := redis_client.GetRedisClient("async_exec_redis")
+	members, err := client.ZRange(delayedKey(queue), 0, -1).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks := make([]Task, len(members))
+	for i, m := range members {
+		if err := jsoniter.Unmarshal([]byte(m), &tasks[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tasks
+}
+
+func TestServerExecute(t *testing.T) {
+	redistest.Start(t, "async_exec_redis")
+	s := NewServer("async_exec_redis")
+	errFailed := errors.New("failed")
+	RegisterHandler("exec_fail", func(ctx context.Context, task *Task) error {
+		return errFailed
+	})
+
+	// 失败后按退避重新投递到延迟队列
+	s.execute(&Task{ID: "retry", Name: "exec_fail", Queue: "retry", MaxRetry: 2})
+	if tasks := delayedTasks(t, "retry"); len(tasks) != 1 || tasks[0].Retried != 1 {
+		t.Errorf("failed task should be retried once, got %+v", tasks)
+	}
+
+	// 达到最大重试次数后不再投递
+	s.execute(&Task{ID: "dead", Name: "exec_fail", Queue: "dead", MaxRetry: 2, Retried: 2})
+	if tasks := delayedTasks(t, "dead"); len(tasks) != 0 {
+		t.Errorf("task should be dropped after max retry, got %+v", tasks)
+	}
+
+	// 超时后等待 handler 返回再重新投递
+	var returned int32
+	RegisterHandler("exec_timeout", func(ctx context.Context, task *Task) error {
+		<-ctx.Done()
+		time.Sleep(20 * time.Millisecond)
+		atomic.StoreInt32(&returned, 1)
+		return ctx.Err()
+	})
+	s.execute(&Task{ID: "timeout", Name: "exec_timeout", Queue: "timeout", MaxRetry: 1, Timeout: 10})
+	if atomic.LoadInt32(&returned) != 1 {
+		t.Error("task should be retried after the handler returns")
+	}
+	if tasks := delayedTasks(t, "timeout"); len(tasks) != 1 || tasks[0].Retried != 1 {
+		t.Errorf("timed out task should be retried once, got %+v", tasks)
+	}
+}
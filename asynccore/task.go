@@ -0,0 +1,161 @@
+// 异步任务, 任务存储在 redis 中, 由 SERVER_TYPE_ASYNC 类型的服务执行
+package asynccore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/golang/protobuf/proto"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/legenove/nano-server-sdk/redis_client"
+	"github.com/legenove/nano-server-sdk/servers"
+	"github.com/legenove/random"
+	"github.com/legenove/utils"
+)
+
+const (
+	CodecJSON  = "json"
+	CodecProto = "proto"
+)
+
+const keyPrefix = "nano:async:"
+
+// Task 队列中的任务
+type Task struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Queue       string `json:"queue"`
+	Codec       string `json:"codec"`
+	Payload     []byte `json:"payload"`
+	Retried     int    `json:"retried"`
+	MaxRetry    int    `json:"max_retry"`
+	Timeout     int64  `json:"timeout"` // 毫秒
+	RequestId   string `json:"request_id"`
+	ServerName  string `json:"server_name"`
+	ServerGroup string `json:"server_group"`
+	EnqueuedAt  int64  `json:"enqueued_at"`
+}
+
+// Unmarshal 按任务的编码方式解析 payload
+func (t *Task) Unmarshal(v interface{}) error {
+	if t.Codec == CodecProto {
+		m, ok := v.(proto.Message)
+		if !ok {
+			return fmt.Errorf("asynccore: task %s payload is proto, %T is not proto.Message", t.Name, v)
+		}
+		return proto.Unmarshal(t.Payload, m)
+	}
+	return jsoniter.Unmarshal(t.Payload, v)
+}
+
+func (t *Task) GetTimeout() time.Duration {
+	return time.Duration(t.Timeout) * time.Millisecond
+}
+
+func readyKey(queue string) string {
+	return utils.ConcatenateStrings(keyPrefix, queue)
+}
+
+func delayedKey(queue string) string {
+	return utils.ConcatenateStrings(keyPrefix, queue, ":delayed")
+}
+
+func processingKey(queue, identity string) string {
+	return utils.ConcatenateStrings(keyPrefix, queue, ":processing:", identity)
+}
+
+// workersKey 消费该队列的副本身份集合
+func workersKey(queue string) string {
+	return utils.ConcatenateStrings(keyPrefix, queue, ":workers")
+}
+
+func heartbeatKey(queue, identity string) string {
+	return utils.ConcatenateStrings(keyPrefix, queue, ":heartbeat:", identity)
+}
+
+type enqueueOptions struct {
+	queue    string
+	delay    time.Duration
+	maxRetry int
+	timeout  time.Duration
+}
+
+type EnqueueOption func(*enqueueOptions)
+
+// WithQueue 任务队列, 默认 default
+func WithQueue(queue string) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.queue = queue
+	}
+}
+
+// WithDelay 延迟执行
+func WithDelay(d time.Duration) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.delay = d
+	}
+}
+
+// WithMaxRetry 失败后最大重试次数, 默认 3
+func WithMaxRetry(n int) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.maxRetry = n
+	}
+}
+
+// WithTimeout 执行超时时间, 默认 1min
+func WithTimeout(d time.Duration) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.timeout = d
+	}
+}
+
+// Enqueue 投递任务, payload 为 proto.Message 时使用 protobuf 编码, 否则使用 json
+// 任务中会带上当前请求 id, 执行时用于串联日志
+func Enqueue(ctx context.Context, routerName, name string, payload interface{}, opts ...EnqueueOption) (string, error) {
+	opt := enqueueOptions{queue: "default", maxRetry: 3, timeout: time.Minute}
+	for _, o := range opts {
+		o(&opt)
+	}
+	task := &Task{
+		ID:          random.UuidV4(),
+		Name:        name,
+		Queue:       opt.queue,
+		MaxRetry:    opt.maxRetry,
+		Timeout:     int64(opt.timeout / time.Millisecond),
+		RequestId:   servers.GetRequestId(ctx),
+		ServerName:  servers.Server.GetServerName(),
+		ServerGroup: servers.Server.GetServerGroup(),
+		EnqueuedAt:  time.Now().Unix(),
+	}
+	var err error
+	if m, ok := payload.(proto.Message); ok {
+		task.Codec = CodecProto
+		task.Payload, err = proto.Marshal(m)
+	} else {
+		task.Codec = CodecJSON
+		task.Payload, err = jsoniter.Marshal(payload)
+	}
+	if err != nil {
+		return "", err
+	}
+	client, err := redis_client.GetRedisClientWithContext(ctx, routerName)
+	if err != nil {
+		return "", err
+	}
+	return task.ID, push(client, task, opt.delay)
+}
+
+func push(client *redis.Client, task *Task, delay time.Duration) error {
+	data, err := jsoniter.Marshal(task)
+	if err != nil {
+		return err
+	}
+	if delay > 0 {
+		due := float64(time.Now().Add(delay).UnixNano()) / float64(time.Second)
+		return client.ZAdd(delayedKey(task.Queue), redis.Z{Score: due, Member: data}).Err()
+	}
+	return client.LPush(readyKey(task.Queue), data).Err()
+}
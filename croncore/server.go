@@ -0,0 +1,171 @@
+// 定时任务, 多副本部署时通过 redis 锁保证每次触发只有一个副本执行
+package croncore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/legenove/nano-server-sdk/redis_lock"
+	"github.com/legenove/nano-server-sdk/servers"
+	"github.com/legenove/nano-server-sdk/stacktrace"
+	"github.com/legenove/random"
+	"github.com/legenove/utils"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/metadata"
+)
+
+type Job func(ctx context.Context) error
+
+type cronJob struct {
+	name     string
+	spec     string
+	schedule Schedule
+	job      Job
+}
+
+var jobMapper = map[string]*cronJob{}
+var mu sync.Mutex
+
+// RegisterJob 注册定时任务, spec 为 cron 表达式或 @every <duration>
+func RegisterJob(name, spec string, job Job) error {
+	schedule, err := Parse(spec)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	jobMapper[name] = &cronJob{name: name, spec: spec, schedule: schedule, job: job}
+	return nil
+}
+
+func getJobs() []*cronJob {
+	mu.Lock()
+	defer mu.Unlock()
+	res := make([]*cronJob, 0, len(jobMapper))
+	for _, j := range jobMapper {
+		res = append(res, j)
+	}
+	return res
+}
+
+type Server struct {
+	locker *redis_lock.Locker
+}
+
+// NewServer 使用 redis 路由创建定时任务服务, lockTTL 为每次触发的锁持有时间, 需大于副本间的时钟偏差
+func NewServer(routerName string, lockTTL time.Duration) (*Server, error) {
+	locker, err := redis_lock.NewLocker(routerName,
+		redis_lock.WithTTL(lockTTL), redis_lock.WithAutoExtend(false))
+	if err != nil {
+		return nil, err
+	}
+	return &Server{locker: locker}, nil
+}
+
+// Run 调度所有注册的任务, 阻塞直到 ctx 结束且执行中的任务完成
+func (s *Server) Run(ctx context.Context) error {
+	jobs := getJobs()
+	if len(jobs) == 0 {
+		return fmt.Errorf("croncore: no job registered")
+	}
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		wg.Add(1)
+		go func(j *cronJob) {
+			defer wg.Done()
+			s.schedule(ctx, j)
+		}(j)
+	}
+	wg.Wait()
+	return nil
+}
+
+func (s *Server) schedule(ctx context.Context, j *cronJob) {
+	var running sync.WaitGroup
+	defer running.Wait()
+	for {
+		next := j.schedule.Next(time.Now())
+		if next.IsZero() {
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		running.Add(1)
+		go func(tick time.Time) {
+			defer running.Done()
+			s.tick(j, tick)
+		}(next)
+	}
+}
+
+// tick 锁的 key 包含触发时间, 锁不主动释放, 保证同一次触发只执行一次
+func (s *Server) tick(j *cronJob, tick time.Time) {
+	key := utils.ConcatenateStrings("nano:cron:", servers.Server.GetServerGroup(), ":",
+		servers.Server.GetServerName(), ":", j.name, ":", strconv.FormatInt(tick.Unix(), 10))
+	ctx := requestCtx(j)
+	if _, err := s.locker.TryLock(context.Background(), key); err != nil {
+		// 其他副本已经执行时跳过, 其他错误会导致本次触发没有副本执行
+		if err != redis_lock.ErrNotObtained {
+			cronError(ctx, j, tick, "10000", "cron lock failed: "+err.Error(), 0)
+		}
+		return
+	}
+	start := time.Now()
+	cronLog(ctx, j, tick, "start", 0)
+	err := call(ctx, j)
+	duration := time.Since(start)
+	if err != nil {
+		cronError(ctx, j, tick, "10001", err.Error(), duration, stacktrace.ErrorFields(err)...)
+		return
+	}
+	cronLog(ctx, j, tick, "finish", duration)
+}
+
+func requestCtx(j *cronJob) context.Context {
+	md := metadata.Pairs(servers.SERVER_INCOME_REQUEST_ID, random.UuidV5())
+	ctx := metadata.NewIncomingContext(servers.GetCronRequestCtx(), md)
+	return servers.InitContext(ctx, j.name, nil)
+}
+
+func call(ctx context.Context, j *cronJob) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = stacktrace.NewPanicError(r)
+		}
+	}()
+	return j.job(ctx)
+}
+
+func cronError(ctx context.Context, j *cronJob, tick time.Time, code, reason string, duration time.Duration, extra ...zap.Field) {
+	logger, err := servers.LogInstance(servers.LogDirError)
+	if err != nil {
+		return
+	}
+	fields := append([]zap.Field{zap.String("job", j.name), zap.Int64("tick", tick.Unix())}, extra...)
+	servers.ErrorLog(logger, ctx, code, reason, duration, fields...)
+}
+
+func cronLog(ctx context.Context, j *cronJob, tick time.Time, status string, duration time.Duration) {
+	logger, err := servers.LogInstance(servers.LogDirAccess)
+	if err != nil {
+		return
+	}
+	servers.AddRequestLog(logger, ctx).Info("cron",
+		zap.String("log_type", servers.LOG_TYPE_APP_ACCESS),
+		zap.String("event", servers.LogEventAccess),
+		zap.Namespace("properties"),
+		zap.String("job", j.name),
+		zap.String("spec", j.spec),
+		zap.Int64("tick", tick.Unix()),
+		zap.String("status", status),
+		zap.Duration("time", duration),
+	)
+}
@@ -0,0 +1,208 @@
+package croncore
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule 计算下一次执行时间
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// everySchedule @every <duration>
+// 触发时间按间隔对齐, 保证各副本计算出相同的触发时间
+type everySchedule struct {
+	every time.Duration
+}
+
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Truncate(s.every).Add(s.every)
+}
+
+// specSchedule 标准 cron 表达式: 分 时 日 月 周
+type specSchedule struct {
+	minute, hour, dom, month, dow uint64
+}
+
+type bounds struct {
+	min, max uint
+	names    map[string]uint
+}
+
+var (
+	minuteBounds = bounds{0, 59, nil}
+	hourBounds   = bounds{0, 23, nil}
+	domBounds    = bounds{1, 31, nil}
+	monthBounds  = bounds{1, 12, map[string]uint{
+		"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+		"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+	}}
+	// 周日可以写作 0 或 7
+	dowBounds = bounds{0, 7, map[string]uint{
+		"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+	}}
+)
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse 解析 cron 表达式, 支持标准的 5 段格式, @every <duration> 与 @daily 等描述符
+// 月与周可以使用 JAN-DEC 与 SUN-SAT, 不区分大小写
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(spec[7:]))
+		if err != nil {
+			return nil, fmt.Errorf("croncore: invalid spec %q: %s", spec, err.Error())
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("croncore: invalid spec %q: interval less than 1s", spec)
+		}
+		return everySchedule{every: d}, nil
+	}
+	if s, ok := descriptors[spec]; ok {
+		spec = s
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("croncore: invalid spec %q: expected 5 fields, got %d", spec, len(fields))
+	}
+	var s specSchedule
+	var err error
+	for i, f := range []struct {
+		bits *uint64
+		b    bounds
+	}{
+		{&s.minute, minuteBounds},
+		{&s.hour, hourBounds},
+		{&s.dom, domBounds},
+		{&s.month, monthBounds},
+		{&s.dow, dowBounds},
+	} {
+		if *f.bits, err = parseField(fields[i], f.b); err != nil {
+			return nil, fmt.Errorf("croncore: invalid spec %q: %s", spec, err.Error())
+		}
+	}
+	if has(s.dow, 7) {
+		s.dow = s.dow&^(1<<7) | 1
+	}
+	return &s, nil
+}
+
+// parseField 解析单个字段, 支持 * , - /
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangeAndStep := strings.SplitN(part, "/", 2)
+		start, end := b.min, b.max
+		step := uint(1)
+		if rangeAndStep[0] != "*" {
+			lowAndHigh := strings.SplitN(rangeAndStep[0], "-", 2)
+			low, err := parseValue(lowAndHigh[0], b)
+			if err != nil {
+				return 0, err
+			}
+			start, end = low, low
+			if len(lowAndHigh) == 2 {
+				if end, err = parseValue(lowAndHigh[1], b); err != nil {
+					return 0, err
+				}
+			} else if len(rangeAndStep) == 2 {
+				end = b.max
+			}
+		}
+		if len(rangeAndStep) == 2 {
+			s, err := parseUint(rangeAndStep[1])
+			if err != nil {
+				return 0, err
+			}
+			if s == 0 {
+				return 0, fmt.Errorf("step of %q must be positive", part)
+			}
+			step = s
+		}
+		if start < b.min || end > b.max || start > end {
+			return 0, fmt.Errorf("%q out of range [%d, %d]", part, b.min, b.max)
+		}
+		for i := start; i <= end; i += step {
+			bits |= 1 << i
+		}
+	}
+	return bits, nil
+}
+
+// parseValue 解析数字或月份, 星期的名称
+func parseValue(s string, b bounds) (uint, error) {
+	if n, ok := b.names[strings.ToUpper(s)]; ok {
+		return n, nil
+	}
+	return parseUint(s)
+}
+
+func parseUint(s string) (uint, error) {
+	n, err := strconv.ParseUint(s, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return uint(n), nil
+}
+
+func has(bits uint64, i int) bool {
+	return bits&(1<<uint(i)) > 0
+}
+
+// Next 返回 t 之后第一个匹配的时间, 五年内无匹配时返回零值
+func (s *specSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !has(s.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !has(s.hour, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !has(s.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches 日与周都有限制时满足其一即可, 与标准 cron 一致
+func (s *specSchedule) dayMatches(t time.Time) bool {
+	domAll := s.dom == allBits(domBounds)
+	dowAll := s.dow == allBits(bounds{min: 0, max: 6})
+	domMatch := has(s.dom, t.Day())
+	dowMatch := has(s.dow, int(t.Weekday()))
+	if domAll || dowAll {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+func allBits(b bounds) uint64 {
+	var bits uint64
+	for i := b.min; i <= b.max; i++ {
+		bits |= 1 << i
+	}
+	return bits
+}
@@ -0,0 +1,45 @@
+package croncore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	base := time.Date(2020, 1, 1, 10, 7, 30, 0, time.UTC) // 周三
+	cases := []struct {
+		spec string
+		next time.Time
+	}{
+		{"*/15 * * * *", time.Date(2020, 1, 1, 10, 15, 0, 0, time.UTC)},
+		{"0 9-18 * * 1-5", time.Date(2020, 1, 1, 11, 0, 0, 0, time.UTC)},
+		{"30 2 * * *", time.Date(2020, 1, 2, 2, 30, 0, 0, time.UTC)},
+		{"0 0 1 3 *", time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2020, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2020, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 5-7", time.Date(2020, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * sun", time.Date(2020, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * MON-FRI", time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 MAR *", time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 feb-apr/2 *", time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * *", time.Date(2020, 1, 13, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2020, 1, 1, 11, 0, 0, 0, time.UTC)},
+		{"@every 5m", time.Date(2020, 1, 1, 10, 10, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		s, err := Parse(c.spec)
+		if err != nil {
+			t.Errorf("%s: %s", c.spec, err)
+			continue
+		}
+		if next := s.Next(base); !next.Equal(c.next) {
+			t.Errorf("%s: expected %s, got %s", c.spec, c.next, next)
+		}
+	}
+	for _, spec := range []string{"* * *", "60 * * * *", "*/0 * * * *", "@every 1ms", "a * * * *",
+		"0 0 * * 8", "0 0 * FOO *", "0 0 * * JAN", "0 0 * SUN *"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("%s: expected error", spec)
+		}
+	}
+}
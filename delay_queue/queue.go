@@ -0,0 +1,333 @@
+// 延迟队列, 任务按到期时间存储在 redis 有序集合中
+// 任务在处理完成前保留在 processing 中, 处理超时会重新投递, 保证至少处理一次
+package delay_queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/legenove/nano-server-sdk/redis_client"
+	"github.com/legenove/nano-server-sdk/servers"
+	"github.com/legenove/nano-server-sdk/stacktrace"
+	"github.com/legenove/random"
+	"github.com/legenove/utils"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/metadata"
+)
+
+// ErrJobProcessing 相同 id 的任务正在处理中, 不能覆盖
+var ErrJobProcessing = errors.New("delay_queue: job is processing")
+
+// Job 延迟任务
+type Job struct {
+	ID        string `json:"id"`
+	Topic     string `json:"topic"`
+	Payload   []byte `json:"payload"`
+	Attempts  int    `json:"attempts"`
+	DueAt     int64  `json:"due_at"` // 毫秒
+	RequestId string `json:"request_id"`
+}
+
+type Handler func(ctx context.Context, job *Job) error
+
+type options struct {
+	concurrency       int
+	pollInterval      time.Duration
+	visibilityTimeout time.Duration
+	maxAttempts       int
+}
+
+type Option func(*options)
+
+// WithConcurrency 同时处理的任务数量, 默认 10
+func WithConcurrency(n int) Option {
+	return func(o *options) {
+		o.concurrency = n
+	}
+}
+
+// WithPollInterval 检查到期任务的间隔, 默认 1s
+func WithPollInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.pollInterval = d
+	}
+}
+
+// WithVisibilityTimeout 处理超时时间, 超时未完成的任务重新投递, 默认 1min
+func WithVisibilityTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.visibilityTimeout = d
+	}
+}
+
+// WithMaxAttempts 最大处理次数, 超过后丢弃并记录日志, 默认 10, 0 为不限制
+func WithMaxAttempts(n int) Option {
+	return func(o *options) {
+		o.maxAttempts = n
+	}
+}
+
+type Queue struct {
+	router   string
+	name     string
+	keys     []string
+	opt      options
+	handlers map[string]Handler
+	mu       sync.RWMutex
+}
+
+func NewQueue(routerName, name string, opts ...Option) *Queue {
+	opt := options{
+		concurrency:       10,
+		pollInterval:      time.Second,
+		visibilityTimeout: time.Minute,
+		maxAttempts:       10,
+	}
+	for _, o := range opts {
+		o(&opt)
+	}
+	prefix := utils.ConcatenateStrings("nano:delay:{", name, "}:")
+	return &Queue{
+		router: routerName,
+		name:   name,
+		// 使用 hash tag 保证在 cluster 中位于同一个 slot
+		keys:     []string{prefix + "jobs", prefix + "delayed", prefix + "ready", prefix + "processing"},
+		opt:      opt,
+		handlers: make(map[string]Handler),
+	}
+}
+
+// Register 注册 topic 的处理函数
+func (q *Queue) Register(topic string, h Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[topic] = h
+}
+
+func (q *Queue) getHandler(topic string) (Handler, bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	h, ok := q.handlers[topic]
+	return h, ok
+}
+
+func (q *Queue) client(ctx context.Context) (*redis.Client, error) {
+	return redis_client.GetRedisClientWithContext(ctx, q.router)
+}
+
+func dueScore(due time.Time) int64 {
+	return due.UnixNano() / int64(time.Millisecond)
+}
+
+// Add 添加任务, 在 delay 后执行, 不传 id 时自动生成
+// 相同 id 会覆盖之前未开始处理的任务, 任务处理中时返回 ErrJobProcessing
+func (q *Queue) Add(ctx context.Context, topic string, payload []byte, delay time.Duration, id ...string) (string, error) {
+	job := &Job{
+		Topic:     topic,
+		Payload:   payload,
+		DueAt:     dueScore(time.Now().Add(delay)),
+		RequestId: servers.GetRequestId(ctx),
+	}
+	if len(id) > 0 && id[0] != "" {
+		job.ID = id[0]
+	} else {
+		job.ID = random.UuidV4()
+	}
+	data, err := jsoniter.Marshal(job)
+	if err != nil {
+		return "", err
+	}
+	client, err := q.client(ctx)
+	if err != nil {
+		return "", err
+	}
+	n, err := addScript.Run(client, q.keys, job.ID, data, job.DueAt).Int64()
+	if err != nil {
+		return "", err
+	}
+	if n == 0 {
+		return "", ErrJobProcessing
+	}
+	return job.ID, nil
+}
+
+// Cancel 取消还未开始处理的任务, 任务不存在或处理中时返回 false
+func (q *Queue) Cancel(ctx context.Context, id string) (bool, error) {
+	client, err := q.client(ctx)
+	if err != nil {
+		return false, err
+	}
+	n, err := cancelScript.Run(client, q.keys, id).Int64()
+	return n == 1, err
+}
+
+// Reschedule 修改未到期任务的执行时间为 delay 之后, 任务不存在或已到期时返回 false
+func (q *Queue) Reschedule(ctx context.Context, id string, delay time.Duration) (bool, error) {
+	client, err := q.client(ctx)
+	if err != nil {
+		return false, err
+	}
+	n, err := rescheduleScript.Run(client, q.keys, id, dueScore(time.Now().Add(delay))).Int64()
+	return n == 1, err
+}
+
+// Run 启动任务处理, 阻塞直到 ctx 结束且处理中的任务完成
+// 每次操作都从 redis_client 获取客户端, 配置热更新后使用新的客户端
+func (q *Queue) Run(ctx context.Context) error {
+	if _, err := redis_client.GetRedisClient(q.router); err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		q.poll(ctx)
+	}()
+	for i := 0; i < q.opt.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// poll 定时移动到期任务与处理超时的任务
+func (q *Queue) poll(ctx context.Context) {
+	ticker := time.NewTicker(q.opt.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		client, err := redis_client.GetRedisClient(q.router)
+		if err != nil {
+			continue
+		}
+		now := dueScore(time.Now())
+		moveDueScript.Run(client, q.keys, now, 100)
+		requeueScript.Run(client, q.keys, now, 100)
+	}
+}
+
+func (q *Queue) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		client, err := redis_client.GetRedisClient(q.router)
+		var data string
+		if err == nil {
+			deadline := dueScore(time.Now().Add(q.opt.visibilityTimeout))
+			data, err = takeScript.Run(client, q.keys, deadline).String()
+		}
+		if err != nil {
+			// 没有任务或者 redis 异常时等待下一次轮询
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(q.opt.pollInterval):
+			}
+			continue
+		}
+		var job Job
+		if err := jsoniter.Unmarshal([]byte(data), &job); err != nil {
+			continue
+		}
+		q.process(client, &job)
+	}
+}
+
+func (q *Queue) process(client *redis.Client, job *Job) {
+	ctx := q.requestCtx(job)
+	// 处理中反复超时的任务不会走到失败分支, 取出时超过次数直接丢弃
+	if q.opt.maxAttempts > 0 && job.Attempts > q.opt.maxAttempts {
+		ackScript.Run(client, q.keys, job.ID)
+		q.log(ctx, job, "dropped", "attempts exceeded", 0)
+		return
+	}
+	start := time.Now()
+	h, ok := q.getHandler(job.Topic)
+	var err error
+	if !ok {
+		err = fmt.Errorf("delay_queue: topic %s handler not registered", job.Topic)
+	} else {
+		err = call(ctx, h, job)
+	}
+	duration := time.Since(start)
+	if err == nil {
+		ackScript.Run(client, q.keys, job.ID)
+		q.log(ctx, job, "success", "", duration)
+		return
+	}
+	if q.opt.maxAttempts > 0 && job.Attempts >= q.opt.maxAttempts {
+		ackScript.Run(client, q.keys, job.ID)
+		q.log(ctx, job, "dropped", err.Error(), duration, stacktrace.ErrorFields(err)...)
+		return
+	}
+	// 退避: 1s, 4s, 9s ...
+	backoff := time.Duration(job.Attempts*job.Attempts) * time.Second
+	job.DueAt = dueScore(time.Now().Add(backoff))
+	data, _ := jsoniter.Marshal(job)
+	retryScript.Run(client, q.keys, job.ID, data, job.DueAt)
+	q.log(ctx, job, "retry", err.Error(), duration, stacktrace.ErrorFields(err)...)
+}
+
+func call(ctx context.Context, h Handler, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = stacktrace.NewPanicError(r)
+		}
+	}()
+	return h(ctx, job)
+}
+
+// requestCtx 请求 id 沿用添加任务时的请求 id
+func (q *Queue) requestCtx(job *Job) context.Context {
+	requestId := job.RequestId
+	if requestId == "" {
+		requestId = job.ID
+	}
+	md := metadata.Pairs(servers.SERVER_INCOME_REQUEST_ID, requestId)
+	ctx := metadata.NewIncomingContext(servers.GetAsyncRequestCtx(), md)
+	return servers.InitContext(ctx, utils.ConcatenateStrings(q.name, ".", job.Topic), job)
+}
+
+func (q *Queue) log(ctx context.Context, job *Job, status, reason string, duration time.Duration, extra ...zap.Field) {
+	logger, err := servers.LogInstance(servers.LogDirAsync)
+	if err != nil {
+		return
+	}
+	fields := []zap.Field{
+		zap.String("log_type", servers.LOG_TYPE_ASYNC),
+		zap.String("event", servers.LogEventAsync),
+		zap.Namespace("properties"),
+		zap.String("queue", q.name),
+		zap.String("topic", job.Topic),
+		zap.String("jobId", job.ID),
+		zap.Int("attempts", job.Attempts),
+		zap.String("status", status),
+		zap.Duration("time", duration),
+	}
+	if reason != "" {
+		fields = append(fields, zap.String("reason", reason))
+	}
+	fields = append(fields, extra...)
+	logger = servers.AddRequestLog(logger, ctx)
+	if status == "success" {
+		logger.Info("delay", fields...)
+	} else {
+		logger.Error("delay", fields...)
+	}
+}
@@ -0,0 +1,171 @@
+package delay_queue
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/legenove/nano-server-sdk/redis_client"
+	"github.com/legenove/nano-server-sdk/redis_client/redistest"
+)
+
+func newTestQueue(t *testing.T, opts ...Option) (*Queue, *redis.Client) {
+	t.Helper()
+	redistest.Start(t, "delay_redis")
+	client, err := redis_client.GetRedisClient("delay_redis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewQueue("delay_redis", "test", opts...), client
+}
+
+// take 将到期任务移入 ready 并取出一个, 处理截止时间为 deadline
+func take(t *testing.T, q *Queue, client *redis.Client, now, deadline time.Time) *Job {
+	t.Helper()
+	moveDueScript.Run(client, q.keys, dueScore(now), 100)
+	data, err := takeScript.Run(client, q.keys, dueScore(deadline)).String()
+	if err == redis.Nil {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := &Job{}
+	if err := jsoniter.Unmarshal([]byte(data), job); err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func TestAddTakeAck(t *testing.T) {
+	q, client := newTestQueue(t)
+	ctx := context.Background()
+	id, err := q.Add(ctx, "topic", []byte("a"), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job := take(t, q, client, time.Now(), time.Now().Add(time.Minute)); job != nil {
+		t.Fatalf("job should not be due yet: %+v", job)
+	}
+	job := take(t, q, client, time.Now().Add(2*time.Minute), time.Now().Add(3*time.Minute))
+	if job == nil || job.ID != id || string(job.Payload) != "a" {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+	if n, _ := ackScript.Run(client, q.keys, id).Int64(); n != 1 {
+		t.Fatal("ack should remove the job")
+	}
+	if n := client.ZCard(q.keys[3]).Val(); n != 0 {
+		t.Errorf("processing should be empty, got %d", n)
+	}
+}
+
+func TestReAdd(t *testing.T) {
+	q, client := newTestQueue(t)
+	ctx := context.Background()
+	if _, err := q.Add(ctx, "topic", []byte("a"), 0, "job1"); err != nil {
+		t.Fatal(err)
+	}
+	moveDueScript.Run(client, q.keys, dueScore(time.Now().Add(time.Second)), 100)
+	// 已在 ready 中的任务被覆盖, 只投递一次
+	if _, err := q.Add(ctx, "topic", []byte("b"), 0, "job1"); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Add(time.Second)
+	job := take(t, q, client, now, now.Add(time.Minute))
+	if job == nil || string(job.Payload) != "b" {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+	if again := take(t, q, client, now, now.Add(time.Minute)); again != nil {
+		t.Fatalf("job delivered twice: %+v", again)
+	}
+	// 处理中的任务不能覆盖
+	if _, err := q.Add(ctx, "topic", []byte("c"), 0, "job1"); err != ErrJobProcessing {
+		t.Fatalf("expected ErrJobProcessing, got %v", err)
+	}
+	ackScript.Run(client, q.keys, "job1")
+	if _, err := q.Add(ctx, "topic", []byte("c"), 0, "job1"); err != nil {
+		t.Fatalf("add after ack: %v", err)
+	}
+}
+
+func TestRequeueOnTimeout(t *testing.T) {
+	q, client := newTestQueue(t)
+	ctx := context.Background()
+	id, _ := q.Add(ctx, "topic", []byte("a"), 0)
+	now := time.Now().Add(time.Second)
+	if job := take(t, q, client, now, now.Add(time.Minute)); job == nil {
+		t.Fatal("job should be taken")
+	}
+	requeueScript.Run(client, q.keys, dueScore(now), 100)
+	if job := take(t, q, client, now, now.Add(time.Minute)); job != nil {
+		t.Fatal("job should not be requeued before timeout")
+	}
+	requeueScript.Run(client, q.keys, dueScore(now.Add(2*time.Minute)), 100)
+	job := take(t, q, client, now, now.Add(3*time.Minute))
+	if job == nil || job.ID != id {
+		t.Fatalf("timed out job should be requeued, got %+v", job)
+	}
+}
+
+func TestRun(t *testing.T) {
+	q, _ := newTestQueue(t, WithPollInterval(10*time.Millisecond), WithConcurrency(1))
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls int32
+	done := make(chan struct{})
+	q.Register("topic", func(ctx context.Context, job *Job) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return errors.New("retry")
+		}
+		close(done)
+		return nil
+	})
+	if _, err := q.Add(ctx, "topic", []byte("a"), 0); err != nil {
+		t.Fatal(err)
+	}
+	stopped := make(chan struct{})
+	go func() {
+		q.Run(ctx)
+		close(stopped)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job was not retried")
+	}
+	cancel()
+	<-stopped
+}
+
+// 超时重新投递也计入处理次数
+func TestAttemptsOnRequeue(t *testing.T) {
+	q, client := newTestQueue(t)
+	ctx := context.Background()
+	q.Add(ctx, "topic", []byte("a"), 0)
+	now := time.Now().Add(time.Second)
+	for i := 1; i <= 3; i++ {
+		job := take(t, q, client, now, now)
+		if job == nil || job.Attempts != i || string(job.Payload) != "a" {
+			t.Fatalf("take %d: unexpected job %+v", i, job)
+		}
+		requeueScript.Run(client, q.keys, dueScore(now), 100)
+	}
+}
+
+func TestReschedule(t *testing.T) {
+	q, client := newTestQueue(t)
+	ctx := context.Background()
+	id, _ := q.Add(ctx, "topic", []byte("a"), time.Minute)
+	if ok, err := q.Reschedule(ctx, id, time.Hour); !ok || err != nil {
+		t.Fatalf("reschedule failed: %v, %v", ok, err)
+	}
+	var job Job
+	jsoniter.Unmarshal([]byte(client.HGet(q.keys[0], id).Val()), &job)
+	due := int64(client.ZScore(q.keys[1], id).Val())
+	if job.DueAt != due || job.DueAt < dueScore(time.Now().Add(59*time.Minute)) {
+		t.Errorf("due_at %d should follow the delayed score %d", job.DueAt, due)
+	}
+}
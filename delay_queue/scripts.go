@@ -0,0 +1,88 @@
+package delay_queue
+
+import "github.com/go-redis/redis"
+
+// KEYS: jobs, delayed, ready, processing
+
+// 写入任务, ARGV: id, job, due
+// 处理中的任务返回 0, 已在 ready 中的任务移出后重新延迟, 避免重复投递
+var addScript = redis.NewScript(`
+if redis.call("zscore", KEYS[4], ARGV[1]) then
+	return 0
+end
+redis.call("lrem", KEYS[3], 0, ARGV[1])
+redis.call("hset", KEYS[1], ARGV[1], ARGV[2])
+redis.call("zadd", KEYS[2], ARGV[3], ARGV[1])
+return 1`)
+
+// 到期任务移入 ready, ARGV: now, limit
+var moveDueScript = redis.NewScript(`
+local ids = redis.call("zrangebyscore", KEYS[2], "-inf", ARGV[1], "limit", 0, ARGV[2])
+for _, id in ipairs(ids) do
+	redis.call("zrem", KEYS[2], id)
+	redis.call("lpush", KEYS[3], id)
+end
+return #ids`)
+
+// 处理超时的任务重新放回 ready, ARGV: now, limit
+var requeueScript = redis.NewScript(`
+local ids = redis.call("zrangebyscore", KEYS[4], "-inf", ARGV[1], "limit", 0, ARGV[2])
+for _, id in ipairs(ids) do
+	redis.call("zrem", KEYS[4], id)
+	redis.call("lpush", KEYS[3], id)
+end
+return #ids`)
+
+// 取出一个任务并记录处理截止时间, 处理次数在取出时累加, 超时重新投递也会计数, ARGV: deadline
+var takeScript = redis.NewScript(`
+local id = redis.call("rpop", KEYS[3])
+if not id then
+	return false
+end
+local data = redis.call("hget", KEYS[1], id)
+if not data then
+	return false
+end
+local job = cjson.decode(data)
+job["attempts"] = (tonumber(job["attempts"]) or 0) + 1
+data = cjson.encode(job)
+redis.call("hset", KEYS[1], id, data)
+redis.call("zadd", KEYS[4], ARGV[1], id)
+return data`)
+
+// 处理完成, ARGV: id
+var ackScript = redis.NewScript(`
+redis.call("zrem", KEYS[4], ARGV[1])
+return redis.call("hdel", KEYS[1], ARGV[1])`)
+
+// 处理失败后重新延迟, ARGV: id, job, due
+var retryScript = redis.NewScript(`
+if redis.call("zrem", KEYS[4], ARGV[1]) == 0 then
+	return 0
+end
+redis.call("hset", KEYS[1], ARGV[1], ARGV[2])
+redis.call("zadd", KEYS[2], ARGV[3], ARGV[1])
+return 1`)
+
+// 取消未开始处理的任务, ARGV: id
+var cancelScript = redis.NewScript(`
+local removed = redis.call("zrem", KEYS[2], ARGV[1]) + redis.call("lrem", KEYS[3], 0, ARGV[1])
+if removed == 0 then
+	return 0
+end
+redis.call("hdel", KEYS[1], ARGV[1])
+return 1`)
+
+// 修改未到期任务的执行时间, ARGV: id, due
+var rescheduleScript = redis.NewScript(`
+if not redis.call("zscore", KEYS[2], ARGV[1]) then
+	return 0
+end
+local data = redis.call("hget", KEYS[1], ARGV[1])
+if data then
+	local job = cjson.decode(data)
+	job["due_at"] = tonumber(ARGV[2])
+	redis.call("hset", KEYS[1], ARGV[1], cjson.encode(job))
+end
+redis.call("zadd", KEYS[2], ARGV[2], ARGV[1])
+return 1`)
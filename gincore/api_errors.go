@@ -3,11 +3,14 @@ package gincore
 import (
 	"fmt"
 	"io"
+	"net/http"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/legenove/cocore"
 	"github.com/legenove/nano-server-sdk/servers"
+	"github.com/legenove/nano-server-sdk/stacktrace"
 )
 
 var DefaultWriter io.Writer = os.Stdout
@@ -32,9 +35,22 @@ func LoggerWithWriter(notlogged ...string) gin.HandlerFunc {
 	}
 
 	return func(c *gin.Context) {
+		start := time.Now()
+		c.Request.Header.Set(servers.SERVER_INCOME_CONTEXT_IP, RequestIP(c.Request))
+		ctx := requestCtx(c)
+		// 路由开启 payload 时保留请求与返回内容, 请求体读取后放回
+		var bw *bodyWriter
+		if servers.CapturePayload(ctx) {
+			max := servers.PayloadMaxBytes()
+			ctx = servers.SetServerRequestInfo(ctx, requestPayload(c.Request, max))
+			bw = &bodyWriter{ResponseWriter: c.Writer, max: max}
+			c.Writer = bw
+		}
+		c.Request = c.Request.WithContext(ctx)
 		defer func() {
 			var reason interface{}
-			if err := recover(); err != nil {
+			err := recover()
+			if err != nil {
 				switch err.(type) {
 				case *servers.ServerError:
 					_err := err.(*servers.ServerError)
@@ -46,10 +62,13 @@ func LoggerWithWriter(notlogged ...string) gin.HandlerFunc {
 						reason = _err.Error()
 						c.JSON(_err.StatusCode(), _err)
 					} else {
-						_stack := stack(3)
-						reason = fmt.Sprintf("[Recovery] panic recovered:\n%s\n%s\n", err, _stack)
+						st := stacktrace.Capture(1)
+						reason = fmt.Sprintf("[Recovery] panic recovered: %s", err)
+						if zlog, lerr := servers.LogInstance(servers.LogDirError); lerr == nil {
+							servers.ErrorLog(zlog, c.Request.Context(), "10001", reason, time.Since(start), st.Fields()...)
+						}
 						if cocore.App.DEBUG {
-							c.JSON(400, servers.NewServerError(reason.(string), "10001", 400))
+							c.JSON(400, servers.NewServerError(fmt.Sprintf("%s\n%s", reason, st), "10001", 400))
 						} else {
 							c.JSON(400, servers.ErrUnKnowRequest)
 						}
@@ -64,8 +83,15 @@ func LoggerWithWriter(notlogged ...string) gin.HandlerFunc {
 
 				}
 			}
+			if _, ok := skip[c.Request.URL.Path]; ok {
+				return
+			}
+			ctx := c.Request.Context()
+			if bw != nil {
+				ctx = servers.SetServerResponseInfo(ctx, bw.payload())
+			}
+			accessLog(ctx, time.Since(start), err != nil || c.Writer.Status() >= http.StatusBadRequest)
 		}()
-		c.Request.Header.Set(servers.SERVER_INCOME_CONTEXT_IP, RequestIP(c.Request))
 		c.Next()
 	}
 }
@@ -0,0 +1,40 @@
+package gincore
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/legenove/nano-server-sdk/servers"
+)
+
+type logLevelRequest struct {
+	Stream        string `json:"stream"`
+	Level         string `json:"level"`
+	RevertSeconds int64  `json:"revert_seconds"`
+}
+
+// LogLevelHandler 查看与修改日志流的运行时级别, 请求头 Nano-Admin-Secret 需要通过校验, 未配置密钥时不可用
+//
+//	GET  返回所有日志流的级别
+//	PUT  {"stream": "access", "level": "debug", "revert_seconds": 600}
+func LogLevelHandler(c *gin.Context) {
+	if !servers.ValidateAdminSecret(c.GetHeader(servers.SERVER_ADMIN_SECRET)) {
+		c.AbortWithStatusJSON(servers.ErrAdminUnauthorized.StatusCode(), servers.ErrAdminUnauthorized)
+		return
+	}
+	if c.Request.Method == http.MethodGet {
+		c.JSON(http.StatusOK, servers.GetLogLevels())
+		return
+	}
+	var req logLevelRequest
+	err := c.ShouldBindJSON(&req)
+	if err == nil {
+		err = servers.SetLogLevel(req.Stream, req.Level, time.Duration(req.RevertSeconds)*time.Second)
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(servers.ErrLogLevelInvalid.StatusCode(), servers.ErrLogLevelInvalid.New([]string{err.Error()}))
+		return
+	}
+	c.JSON(http.StatusOK, servers.GetLogLevels())
+}
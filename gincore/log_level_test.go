@@ -0,0 +1,50 @@
+package gincore
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/legenove/nano-server-sdk/servers"
+)
+
+func TestLogLevelHandlerAuth(t *testing.T) {
+	defer func(s []servers.ServerSecret) { servers.Server.Secrets = s }(servers.Server.Secrets)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/log_level", LogLevelHandler)
+	do := func(method, secret, body string) int {
+		req := httptest.NewRequest(method, "/log_level", strings.NewReader(body))
+		if secret != "" {
+			req.Header.Set(servers.SERVER_ADMIN_SECRET, secret)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 未配置密钥时管理接口不可用
+	servers.Server.Secrets = []servers.ServerSecret{{Type: servers.SecretNormalType, Secret: ""}}
+	if code := do(http.MethodGet, "", ""); code != http.StatusUnauthorized {
+		t.Errorf("empty secret should be rejected, got %d", code)
+	}
+
+	servers.Server.Secrets = []servers.ServerSecret{{Type: servers.SecretNormalType, Secret: "s3cret"}}
+	if code := do(http.MethodGet, "", ""); code != http.StatusUnauthorized {
+		t.Errorf("missing header should be rejected, got %d", code)
+	}
+	if code := do(http.MethodGet, "wrong", ""); code != http.StatusUnauthorized {
+		t.Errorf("wrong secret should be rejected, got %d", code)
+	}
+	if code := do(http.MethodGet, "s3cret", ""); code != http.StatusOK {
+		t.Errorf("valid secret should be accepted, got %d", code)
+	}
+	if code := do(http.MethodPut, "s3cret", `{"stream":"gin_test","level":"debug"}`); code != http.StatusOK {
+		t.Errorf("set level should succeed, got %d", code)
+	}
+	if code := do(http.MethodPut, "s3cret", `{"stream":"gin_test","level":"loud"}`); code != http.StatusBadRequest {
+		t.Errorf("invalid level should be rejected, got %d", code)
+	}
+}
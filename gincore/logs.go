@@ -0,0 +1,45 @@
+package gincore
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/legenove/nano-server-sdk/servers"
+	"google.golang.org/grpc/metadata"
+)
+
+// 从请求头读取的请求信息, 与 grpc metadata 保持一致
+var incomeHeaders = []string{
+	servers.SERVER_INCOME_REQUEST_ID,
+	servers.SERVER_INCOME_SERVER_NAME,
+	servers.SERVER_INCOME_SERVER_GROUP,
+	servers.SERVER_INCOME_CONTEXT_IP,
+	servers.SERVER_INCOME_USER_AGENT,
+	servers.SERVER_INCOME_DEBUG,
+}
+
+// requestCtx 创建请求上下文, 路由路径作为 requestFunc
+func requestCtx(c *gin.Context) context.Context {
+	md := metadata.MD{}
+	for _, key := range incomeHeaders {
+		if v := c.Request.Header.Get(key); v != "" {
+			md.Set(key, v)
+		}
+	}
+	ctx := metadata.NewIncomingContext(c.Request.Context(), md)
+	ctx = servers.AppendToRequestCtx(ctx, servers.SERVER_REQUEST_TYPE, servers.GetServerTypeValue(servers.REQUEST_TYPE_REST))
+	return servers.InitContext(ctx, c.FullPath(), nil)
+}
+
+// accessLog 失败, 慢请求与 debug 请求总是记录, 其余按采样规则记录
+func accessLog(ctx context.Context, duration time.Duration, failed bool) {
+	if !servers.ShouldAccessLog(ctx, duration, failed) {
+		return
+	}
+	log, err := servers.LogInstance(servers.LogDirAccess)
+	if err != nil {
+		return
+	}
+	servers.AccessLog(log, ctx, duration)
+}
@@ -0,0 +1,86 @@
+package gincore
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 超过 payload_max_bytes 的内容无法完整解析, 为避免输出未脱敏的片段不记录
+const payloadTooLarge = "(exceeds payload_max_bytes)"
+
+// readBody 读取最多 max 字节的请求体并放回 req.Body, 超过 max 时返回 false
+func readBody(req *http.Request, max int) ([]byte, bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, true
+	}
+	head, err := ioutil.ReadAll(io.LimitReader(req.Body, int64(max)+1))
+	req.Body = readCloser{io.MultiReader(bytes.NewReader(head), req.Body), req.Body}
+	if err != nil {
+		return nil, false
+	}
+	return head, len(head) <= max
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// requestPayload 请求体为空时记录 query 参数
+func requestPayload(req *http.Request, max int) interface{} {
+	body, ok := readBody(req, max)
+	if !ok {
+		return payloadTooLarge
+	}
+	if len(body) > 0 {
+		return body
+	}
+	if req.URL.RawQuery != "" {
+		return req.URL.Query()
+	}
+	return nil
+}
+
+// bodyWriter 写出返回内容的同时保留最多 max 字节
+type bodyWriter struct {
+	gin.ResponseWriter
+	buf      bytes.Buffer
+	max      int
+	overflow bool
+}
+
+func (w *bodyWriter) keep(n int, write func()) {
+	if w.overflow {
+		return
+	}
+	if w.buf.Len()+n > w.max {
+		w.overflow = true
+		w.buf.Reset()
+		return
+	}
+	write()
+}
+
+func (w *bodyWriter) Write(b []byte) (int, error) {
+	w.keep(len(b), func() { w.buf.Write(b) })
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyWriter) WriteString(s string) (int, error) {
+	w.keep(len(s), func() { w.buf.WriteString(s) })
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *bodyWriter) payload() interface{} {
+	if w.overflow {
+		return payloadTooLarge
+	}
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	return w.buf.Bytes()
+}
@@ -0,0 +1,66 @@
+package gincore
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestPayload(t *testing.T) {
+	body := `{"name":"n","password":"p"}`
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+	if got, ok := requestPayload(req, 64).([]byte); !ok || string(got) != body {
+		t.Errorf("unexpected payload: %v", got)
+	}
+	// 读取后请求体仍然完整
+	if data, _ := ioutil.ReadAll(req.Body); string(data) != body {
+		t.Errorf("body should be restored, got %s", data)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+	if got := requestPayload(req, 8); got != payloadTooLarge {
+		t.Errorf("large body should not be captured, got %v", got)
+	}
+	if data, _ := ioutil.ReadAll(req.Body); string(data) != body {
+		t.Errorf("large body should be restored, got %s", data)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/users?page=1&token=t", nil)
+	want := url.Values{"page": {"1"}, "token": {"t"}}
+	if got := requestPayload(req, 64); !reflect.DeepEqual(got, want) {
+		t.Errorf("query should be captured when body is empty, got %v", got)
+	}
+}
+
+func TestBodyWriter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var payloads []interface{}
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		bw := &bodyWriter{ResponseWriter: c.Writer, max: 16}
+		c.Writer = bw
+		c.Next()
+		payloads = append(payloads, bw.payload())
+	})
+	r.GET("/small", func(c *gin.Context) { c.String(200, "ok") })
+	r.GET("/large", func(c *gin.Context) { c.String(200, strings.Repeat("x", 32)) })
+	for _, path := range []string{"/small", "/large"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Body.Len() == 0 {
+			t.Errorf("%s: response should be written", path)
+		}
+	}
+	if b, ok := payloads[0].([]byte); !ok || string(b) != "ok" {
+		t.Errorf("unexpected payload: %v", payloads[0])
+	}
+	if payloads[1] != payloadTooLarge {
+		t.Errorf("large response should not be captured, got %v", payloads[1])
+	}
+}
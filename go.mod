@@ -3,17 +3,21 @@ module github.com/legenove/nano-server-sdk
 go 1.14
 
 require (
+	github.com/alicebob/miniredis/v2 v2.23.0
 	github.com/ghodss/yaml v1.0.0
 	github.com/gin-gonic/gin v1.6.3
 	github.com/go-redis/redis v6.15.9+incompatible
+	github.com/go-sql-driver/mysql v1.5.0
 	github.com/golang/protobuf v1.4.2
 	github.com/json-iterator/go v1.1.10
 	github.com/legenove/cocore v1.0.10
 	github.com/legenove/random v0.0.0-20200903103743-63e912aed639
 	github.com/legenove/utils v0.0.0-20200903023119-a6d42e758182
 	github.com/legenove/viper_conf v1.0.3
+	github.com/modern-go/reflect2 v1.0.2 // indirect
 	github.com/onsi/ginkgo v1.14.2 // indirect
 	github.com/onsi/gomega v1.10.3 // indirect
 	go.uber.org/zap v1.15.0
 	google.golang.org/grpc v1.21.1
+	google.golang.org/protobuf v1.23.0
 )
@@ -0,0 +1,119 @@
+package grpccore
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	structpb "github.com/golang/protobuf/ptypes/struct"
+	"github.com/legenove/nano-server-sdk/servers"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// LogLevelServer 日志级别管理服务 nano.admin.LogLevel, 请求与返回使用 google.protobuf.Struct
+//
+//	GetLogLevels {} -> {"levels": [{"stream": "access", "level": "info"}]}
+//	SetLogLevel  {"stream": "access", "level": "debug", "revert_seconds": 600} -> 同 GetLogLevels
+type LogLevelServer interface {
+	GetLogLevels(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	SetLogLevel(context.Context, *structpb.Struct) (*structpb.Struct, error)
+}
+
+// RegisterLogLevelService 在 GetServerWithOptions 创建的服务上注册日志级别管理服务
+// 调用方需要在 metadata 中带上 Nano-Admin-Secret
+func RegisterLogLevelService() {
+	RegisterToServer("logLevel", func(s *grpc.Server) {
+		s.RegisterService(&logLevelServiceDesc, logLevelServer{})
+	})
+}
+
+type logLevelServer struct{}
+
+func (logLevelServer) GetLogLevels(ctx context.Context, _ *structpb.Struct) (*structpb.Struct, error) {
+	if err := checkAdminSecret(ctx); err != nil {
+		return nil, err
+	}
+	return logLevelsStruct(), nil
+}
+
+func (logLevelServer) SetLogLevel(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	if err := checkAdminSecret(ctx); err != nil {
+		return nil, err
+	}
+	fields := req.GetFields()
+	revert := time.Duration(fields["revert_seconds"].GetNumberValue()) * time.Second
+	err := servers.SetLogLevel(fields["stream"].GetStringValue(), fields["level"].GetStringValue(), revert)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return logLevelsStruct(), nil
+}
+
+func checkAdminSecret(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get(strings.ToLower(servers.SERVER_ADMIN_SECRET)) {
+		if servers.ValidateAdminSecret(v) {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, servers.ErrAdminUnauthorized.Msg)
+}
+
+func logLevelsStruct() *structpb.Struct {
+	levels := servers.GetLogLevels()
+	values := make([]*structpb.Value, len(levels))
+	for i, l := range levels {
+		item := map[string]*structpb.Value{
+			"stream": stringValue(l.Stream),
+			"level":  stringValue(l.Level),
+		}
+		if l.RevertAt != "" {
+			item["revert_at"] = stringValue(l.RevertAt)
+			item["revert_to"] = stringValue(l.RevertToLv)
+		}
+		values[i] = &structpb.Value{Kind: &structpb.Value_StructValue{StructValue: &structpb.Struct{Fields: item}}}
+	}
+	return &structpb.Struct{Fields: map[string]*structpb.Value{
+		"levels": {Kind: &structpb.Value_ListValue{ListValue: &structpb.ListValue{Values: values}}},
+	}}
+}
+
+func stringValue(s string) *structpb.Value {
+	return &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: s}}
+}
+
+func logLevelHandler(method string, call func(LogLevelServer, context.Context, *structpb.Struct) (*structpb.Struct, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: method,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			in := new(structpb.Struct)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return call(srv.(LogLevelServer), ctx, in)
+			}
+			info := &grpc.UnaryServerInfo{
+				Server:     srv,
+				FullMethod: "/nano.admin.LogLevel/" + method,
+			}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return call(srv.(LogLevelServer), ctx, req.(*structpb.Struct))
+			}
+			return interceptor(ctx, in, info, handler)
+		},
+	}
+}
+
+var logLevelServiceDesc = grpc.ServiceDesc{
+	ServiceName: "nano.admin.LogLevel",
+	HandlerType: (*LogLevelServer)(nil),
+	Methods: []grpc.MethodDesc{
+		logLevelHandler("GetLogLevels", LogLevelServer.GetLogLevels),
+		logLevelHandler("SetLogLevel", LogLevelServer.SetLogLevel),
+	},
+	Streams: []grpc.StreamDesc{},
+}
@@ -0,0 +1,53 @@
+package grpccore
+
+import (
+	"context"
+	"testing"
+
+	structpb "github.com/golang/protobuf/ptypes/struct"
+	"github.com/legenove/nano-server-sdk/servers"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestLogLevelServiceAuth(t *testing.T) {
+	defer func(s []servers.ServerSecret) { servers.Server.Secrets = s }(servers.Server.Secrets)
+	srv := logLevelServer{}
+	call := func(secret string) codes.Code {
+		ctx := context.Background()
+		if secret != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(servers.SERVER_ADMIN_SECRET, secret))
+		}
+		_, err := srv.GetLogLevels(ctx, &structpb.Struct{})
+		return status.Code(err)
+	}
+
+	// 未配置密钥时管理接口不可用, md5 模式下空密钥也不能通过
+	servers.Server.Secrets = []servers.ServerSecret{{Type: servers.SecretMD5Type, Secret: ""}}
+	if code := call(""); code != codes.Unauthenticated {
+		t.Errorf("empty secret should be rejected, got %s", code)
+	}
+	if code := call("d41d8cd98f00b204e9800998ecf8427e"); code != codes.Unauthenticated {
+		t.Errorf("md5 of empty secret should be rejected, got %s", code)
+	}
+
+	servers.Server.Secrets = []servers.ServerSecret{{Type: servers.SecretNormalType, Secret: "s3cret"}}
+	if code := call(""); code != codes.Unauthenticated {
+		t.Errorf("missing metadata should be rejected, got %s", code)
+	}
+	if code := call("wrong"); code != codes.Unauthenticated {
+		t.Errorf("wrong secret should be rejected, got %s", code)
+	}
+	if code := call("s3cret"); code != codes.OK {
+		t.Errorf("valid secret should be accepted, got %s", code)
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(servers.SERVER_ADMIN_SECRET, "s3cret"))
+	req := &structpb.Struct{Fields: map[string]*structpb.Value{
+		"stream": {Kind: &structpb.Value_StringValue{StringValue: "grpc_test"}},
+		"level":  {Kind: &structpb.Value_StringValue{StringValue: "debug"}},
+	}}
+	if _, err := srv.SetLogLevel(ctx, req); err != nil {
+		t.Errorf("set level should succeed: %v", err)
+	}
+}
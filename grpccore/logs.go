@@ -3,11 +3,11 @@ package grpccore
 import (
 	"context"
 	"fmt"
-	"github.com/legenove/cocore"
-	"github.com/legenove/nano-server-sdk/servers"
-	"math/rand"
 	"time"
 
+	"github.com/legenove/nano-server-sdk/servers"
+	"github.com/legenove/nano-server-sdk/stacktrace"
+	"go.uber.org/zap/zapcore"
 	"google.golang.org/grpc"
 )
 
@@ -18,6 +18,7 @@ func LoggerRecoveryHandler(funcName string, handler grpc.UnaryHandler) grpc.Unar
 		defer func() {
 			var reason interface{}
 			var error_code interface{}
+			var stackFields []zapcore.Field
 			if err := recover(); err != nil {
 				duration := time.Since(start)
 				logDir := servers.LogDirError
@@ -37,9 +38,9 @@ func LoggerRecoveryHandler(funcName string, handler grpc.UnaryHandler) grpc.Unar
 						// 定义的error 在warn日志中
 						logDir = servers.LogDirWarn
 					} else {
-						_stack := stack(3)
-						reason = fmt.Sprintf("[Recovery] panic recovered:\n%s\n%s\n", err, _stack)
+						reason = fmt.Sprintf("[Recovery] panic recovered: %s", err)
 						error_code = "10001"
+						stackFields = stacktrace.Capture(1).Fields()
 					}
 				default:
 					error_code = "10000"
@@ -51,27 +52,28 @@ func LoggerRecoveryHandler(funcName string, handler grpc.UnaryHandler) grpc.Unar
 				}
 
 				// 未定义的错误，在error中， 定义的错误在warn中
-				zlog, _ := cocore.LogPool.Instance(logDir)
-				servers.WarnLog(zlog, ctx, error_code, reason, duration)
+				zlog, _ := servers.LogInstance(logDir)
+				servers.WarnLog(zlog, ctx, error_code, reason, duration, stackFields...)
+				accessLog(ctx, duration, true)
 			}
 		}()
 		ctx = servers.InitContext(ctx, funcName, req)
 		res, err := handler(ctx, req)
 		// after
-		// Log only when path is not being skipped
-		var accesslog bool
-		if servers.OpenAccessLog <= 0 {
-			accesslog = false
-		} else if servers.OpenAccessLog >= 100 {
-			accesslog = true
-		} else if rand.Int()/100 < servers.OpenAccessLog {
-			accesslog = true
-		}
-		if accesslog {
-			duration := time.Since(start)
-			log, _ := cocore.LogPool.Instance(servers.LogDirAccess)
-			servers.AccessLog(log, ctx, duration)
-		}
+		ctx = servers.SetServerResponseInfo(ctx, res)
+		accessLog(ctx, time.Since(start), err != nil)
 		return res, err
 	}
 }
+
+// accessLog 失败, 慢请求与 debug 请求总是记录, 其余按采样规则记录
+func accessLog(ctx context.Context, duration time.Duration, failed bool) {
+	if !servers.ShouldAccessLog(ctx, duration, failed) {
+		return
+	}
+	log, err := servers.LogInstance(servers.LogDirAccess)
+	if err != nil {
+		return
+	}
+	servers.AccessLog(log, ctx, duration)
+}
@@ -0,0 +1,206 @@
+package http_client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/legenove/cocore"
+	"github.com/legenove/nano-server-sdk/servers"
+	"github.com/legenove/viper_conf"
+	"go.uber.org/zap"
+)
+
+type mangers struct {
+	clients map[string]*Client
+	sync.Mutex
+}
+
+var httpConf *viper_conf.ViperConf
+var Manager = &mangers{clients: make(map[string]*Client)}
+
+// 加载时的原始配置，热更新时使用原始配置对比
+var httpRawSettings = make(map[string]HttpSetting)
+
+// Client 配置文件中的一个 http 客户端
+type Client struct {
+	*http.Client
+	setting *HttpSetting
+}
+
+func getHttpConf(key string) (*HttpSetting, error) {
+	if httpConf == nil {
+		err := newHttpConfig()
+		if err != nil {
+			return nil, err
+		}
+		if httpConf == nil {
+			return nil, fmt.Errorf("http conf not setting")
+		}
+	}
+	var setting HttpSetting
+	err := httpConf.GetConf().UnmarshalKey(key, &setting)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid http conf:%s; err:%s", key, err.Error())
+	}
+	if setting.RouterName == "" {
+		setting.RouterName = key
+	}
+	raw := setting
+	if err := setting.Validate(); err != nil {
+		return nil, err
+	}
+	httpRawSettings[setting.RouterName] = raw
+	return &setting, nil
+}
+
+func newHttpConfig() error {
+	var err error
+	httpFileName := cocore.App.GetStringConfig("http_conf", "http.toml")
+	httpConf, err = cocore.Conf.Instance(httpFileName, nil)
+	go listenOnHttpChange(httpConf)
+	return err
+}
+
+func listenOnHttpChange(v *viper_conf.ViperConf) {
+	if v != nil {
+		<-v.OnChange
+		for {
+			select {
+			case <-v.OnChange:
+				reloadHttp()
+			}
+		}
+	}
+}
+
+// reloadHttp 移除配置发生变化的客户端, 下次获取时重新创建
+// 旧客户端的空闲链接在执行中的请求结束后关闭
+func reloadHttp() {
+	conf := httpConf.GetConf()
+	if conf == nil {
+		return
+	}
+	Manager.Lock()
+	defer Manager.Unlock()
+	var changed []string
+	for name, old := range httpRawSettings {
+		var setting HttpSetting
+		if err := conf.UnmarshalKey(name, &setting); err != nil {
+			continue
+		}
+		if setting.RouterName == "" {
+			setting.RouterName = name
+		}
+		if reflect.DeepEqual(old, setting) {
+			continue
+		}
+		changed = append(changed, name)
+		if c, ok := Manager.clients[name]; ok {
+			c.CloseIdleConnections()
+			delete(Manager.clients, name)
+		}
+		delete(httpRawSettings, name)
+	}
+	if logger, err := servers.LogInstance(servers.LogDirRequest); err == nil && len(changed) > 0 {
+		logger.Info("reload",
+			zap.String("log_type", servers.LOG_TYPE_REQUEST),
+			zap.String("event", servers.LogEventRequest),
+			zap.Namespace("properties"),
+			zap.Strings("changed", changed),
+		)
+	}
+}
+
+func GetHttpClient(key string) (*Client, error) {
+	return Manager.GetHttpClient(key)
+}
+
+func (m *mangers) GetHttpClient(key string) (*Client, error) {
+	m.Lock()
+	defer m.Unlock()
+	if c, ok := m.clients[key]; ok {
+		return c, nil
+	}
+	setting, err := getHttpConf(key)
+	if err != nil {
+		return nil, err
+	}
+	c := NewClient(setting)
+	m.clients[key] = c
+	return c, nil
+}
+
+// NewClient 不使用配置文件直接创建客户端
+func NewClient(setting *HttpSetting) *Client {
+	dialer := &net.Dialer{Timeout: setting.GetDialTimeout(), KeepAlive: 30 * time.Second}
+	base := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       setting.GetTLSConfig(),
+		MaxIdleConns:          setting.GetMaxIdleConns(),
+		MaxIdleConnsPerHost:   setting.GetMaxIdleConnsPerHost(),
+		IdleConnTimeout:       setting.GetIdleConnTimeout(),
+		ResponseHeaderTimeout: setting.GetResponseHeaderTimeout(),
+		TLSHandshakeTimeout:   setting.GetDialTimeout(),
+		ForceAttemptHTTP2:     true,
+	}
+	if proxy := setting.GetProxyURL(); proxy != nil {
+		base.Proxy = http.ProxyURL(proxy)
+	}
+	return &Client{
+		Client: &http.Client{
+			Timeout: setting.GetTimeout(),
+			Transport: &nanoTransport{
+				name:      setting.RouterName,
+				base:      base,
+				retryMax:  setting.GetRetryMax(),
+				retryWait: setting.GetRetryWait(),
+			},
+		},
+		setting: setting,
+	}
+}
+
+// URL 拼接 BaseURL 与 path, path 为完整地址时直接返回
+func (c *Client) URL(path string) string {
+	if c.setting.BaseURL == "" || strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	return strings.TrimSuffix(c.setting.BaseURL, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
+// NewRequest 创建带请求上下文的请求, 请求 id 会通过请求头传递
+func (c *Client) NewRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	return http.NewRequestWithContext(requestContext(ctx), method, c.URL(path), body)
+}
+
+func (c *Client) Get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := c.NewRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// PostJSON 以 json 编码 v 发送 POST 请求
+func (c *Client) PostJSON(ctx context.Context, path string, v interface{}) (*http.Response, error) {
+	data, err := jsoniter.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	req, err := c.NewRequest(ctx, http.MethodPost, path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.Do(req)
+}
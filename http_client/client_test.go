@@ -0,0 +1,64 @@
+package http_client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/legenove/nano-server-sdk/servers"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestClientRetryAndHeaders(t *testing.T) {
+	var calls int32
+	var requestId string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId = r.Header.Get(servers.SERVER_INCOME_REQUEST_ID)
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	setting := &HttpSetting{RouterName: "test", BaseURL: server.URL + "/api/", RetryMax: 2, RetryWait: 1}
+	if err := setting.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(setting)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(servers.SERVER_INCOME_REQUEST_ID, "req-1"))
+	resp, err := c.Get(ctx, "/users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || atomic.LoadInt32(&calls) != 3 {
+		t.Errorf("GET should be retried until success, status %d, calls %d", resp.StatusCode, calls)
+	}
+	if requestId != "req-1" {
+		t.Errorf("request id should be passed, got %q", requestId)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	resp, err = c.PostJSON(ctx, "users", map[string]string{"name": "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("POST should not be retried, calls %d", calls)
+	}
+}
+
+func TestClientURL(t *testing.T) {
+	c := &Client{setting: &HttpSetting{BaseURL: "http://svc/api/"}}
+	if u := c.URL("/users"); u != "http://svc/api/users" {
+		t.Errorf("unexpected url %s", u)
+	}
+	if u := c.URL("https://other/x"); u != "https://other/x" {
+		t.Errorf("absolute url should be kept, got %s", u)
+	}
+}
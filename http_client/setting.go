@@ -0,0 +1,153 @@
+package http_client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"time"
+)
+
+type HttpSetting struct {
+	RouterName            string
+	BaseURL               string // 请求路径的前缀, 如 http://user-service:8080/api
+	Timeout               int    // 整个请求的超时, 毫秒, 默认 10000
+	DialTimeout           int    // 创建链接超时, 毫秒, 默认 3000
+	ResponseHeaderTimeout int    // 等待响应头超时, 毫秒, 默认不限制
+	IdleConnTimeout       int    // 空闲链接保留时间, 秒, 默认 90
+	MaxIdleConns          int    // 最大空闲链接数量, 默认 100
+	MaxIdleConnsPerHost   int    // 每个 host 最大空闲链接数量, 默认 10
+	Proxy                 string // 代理地址, 为空时使用环境变量 HTTP_PROXY
+	TLSCAFile             string // CA 证书路径, 为空时使用系统证书
+	TLSCertFile           string // 客户端证书路径
+	TLSKeyFile            string // 客户端私钥路径
+	TLSServerName         string // 校验的服务端名称
+	TLSInsecureSkipVerify bool   // 跳过证书校验, 仅用于开发环境
+	RetryMax              int    // 失败后最大重试次数, 只重试幂等请求, 默认不重试
+	RetryWait             int    // 重试间隔, 毫秒, 第 n 次重试等待 n 倍, 默认 100
+	tlsConfig             *tls.Config
+	proxyURL              *url.URL
+}
+
+// Validate 校验配置，错误信息中包含路由名
+func (s *HttpSetting) Validate() error {
+	if s.BaseURL != "" {
+		u, err := url.Parse(s.BaseURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("http conf %s: invalid BaseURL: %s", s.RouterName, s.BaseURL)
+		}
+	}
+	if s.Proxy != "" {
+		u, err := url.Parse(s.Proxy)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("http conf %s: invalid Proxy: %s", s.RouterName, s.Proxy)
+		}
+		s.proxyURL = u
+	}
+	if s.RetryMax < 0 {
+		return fmt.Errorf("http conf %s: RetryMax must not be negative: %d", s.RouterName, s.RetryMax)
+	}
+	if (s.TLSCertFile == "") != (s.TLSKeyFile == "") {
+		return fmt.Errorf("http conf %s: TLSCertFile and TLSKeyFile must be set together", s.RouterName)
+	}
+	tlsConfig, err := s.newTLSConfig()
+	if err != nil {
+		return fmt.Errorf("http conf %s: %s", s.RouterName, err.Error())
+	}
+	s.tlsConfig = tlsConfig
+	return nil
+}
+
+func (s *HttpSetting) newTLSConfig() (*tls.Config, error) {
+	if s.TLSCAFile == "" && s.TLSCertFile == "" && s.TLSServerName == "" && !s.TLSInsecureSkipVerify {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{
+		ServerName:         s.TLSServerName,
+		InsecureSkipVerify: s.TLSInsecureSkipVerify,
+	}
+	if s.TLSCAFile != "" {
+		ca, err := ioutil.ReadFile(s.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read TLSCAFile failed: %s", err.Error())
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("TLSCAFile has no valid certificate: %s", s.TLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if s.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(s.TLSCertFile, s.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load TLSCertFile/TLSKeyFile failed: %s", err.Error())
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+func (s *HttpSetting) GetBaseURL() string {
+	return s.BaseURL
+}
+
+func (s *HttpSetting) GetTimeout() time.Duration {
+	if s.Timeout <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(s.Timeout) * time.Millisecond
+}
+
+func (s *HttpSetting) GetDialTimeout() time.Duration {
+	if s.DialTimeout <= 0 {
+		return 3 * time.Second
+	}
+	return time.Duration(s.DialTimeout) * time.Millisecond
+}
+
+func (s *HttpSetting) GetResponseHeaderTimeout() time.Duration {
+	return time.Duration(s.ResponseHeaderTimeout) * time.Millisecond
+}
+
+func (s *HttpSetting) GetIdleConnTimeout() time.Duration {
+	if s.IdleConnTimeout <= 0 {
+		return 90 * time.Second
+	}
+	return time.Duration(s.IdleConnTimeout) * time.Second
+}
+
+func (s *HttpSetting) GetMaxIdleConns() int {
+	if s.MaxIdleConns <= 0 {
+		return 100
+	}
+	return s.MaxIdleConns
+}
+
+func (s *HttpSetting) GetMaxIdleConnsPerHost() int {
+	if s.MaxIdleConnsPerHost <= 0 {
+		return 10
+	}
+	return s.MaxIdleConnsPerHost
+}
+
+// GetProxyURL 未配置时返回 nil, 使用环境变量中的代理
+func (s *HttpSetting) GetProxyURL() *url.URL {
+	return s.proxyURL
+}
+
+// GetTLSConfig 未配置 TLS 相关项时返回 nil, 使用默认配置
+func (s *HttpSetting) GetTLSConfig() *tls.Config {
+	return s.tlsConfig
+}
+
+func (s *HttpSetting) GetRetryMax() int {
+	return s.RetryMax
+}
+
+func (s *HttpSetting) GetRetryWait() time.Duration {
+	if s.RetryWait <= 0 {
+		return 100 * time.Millisecond
+	}
+	return time.Duration(s.RetryWait) * time.Millisecond
+}
@@ -0,0 +1,135 @@
+package http_client
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/legenove/nano-server-sdk/servers"
+	"go.uber.org/zap"
+)
+
+// nanoTransport 注入 Nano-* 请求头, 失败时重试幂等请求, 每次请求写入请求日志
+type nanoTransport struct {
+	name      string
+	base      http.RoundTripper
+	retryMax  int
+	retryWait time.Duration
+}
+
+func (t *nanoTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = injectHeaders(req)
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+		start := time.Now()
+		resp, err := t.base.RoundTrip(req)
+		logRequest(t.name, req, resp, attempt, time.Since(start), err)
+		if attempt >= t.retryMax || !shouldRetry(req, resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(time.Duration(attempt+1) * t.retryWait):
+		}
+	}
+}
+
+// injectHeaders 传递请求 id 与调用方 ip, 服务名与服务组为当前服务
+func injectHeaders(req *http.Request) *http.Request {
+	ctx := req.Context()
+	req = req.Clone(ctx)
+	if r := servers.GetServerIncomeByKey(servers.SERVER_INCOME_REQUEST_ID, ctx); len(r) > 0 && r[0] != "" {
+		req.Header.Set(servers.SERVER_INCOME_REQUEST_ID, r[0])
+	}
+	if servers.IsDebugRequest(ctx) {
+		req.Header.Set(servers.SERVER_INCOME_DEBUG, "1")
+	}
+	if ip := servers.GetContextIP(ctx); ip != "" {
+		req.Header.Set(servers.SERVER_INCOME_CONTEXT_IP, ip)
+	}
+	if name := servers.Server.GetServerName(); name != "" {
+		req.Header.Set(servers.SERVER_INCOME_SERVER_NAME, name)
+	}
+	if group := servers.Server.GetServerGroup(); group != "" {
+		req.Header.Set(servers.SERVER_INCOME_SERVER_GROUP, group)
+	}
+	return req
+}
+
+// shouldRetry 只重试幂等且 body 可重读的请求, 网络错误与 502/503/504 时重试
+func shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	if req.Body != nil && req.GetBody == nil {
+		return false
+	}
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func logRequest(name string, req *http.Request, resp *http.Response, attempt int, duration time.Duration, err error) {
+	logger, lerr := servers.LogInstance(servers.LogDirRequest)
+	if lerr != nil {
+		return
+	}
+	u := *req.URL
+	u.User = nil
+	u.RawQuery = ""
+	fields := []zap.Field{
+		zap.String("log_type", servers.LOG_TYPE_REQUEST),
+		zap.String("event", servers.LogEventRequest),
+		zap.String("logServer", servers.Server.GetServerName()),
+		zap.String("logServerGroup", servers.Server.GetServerGroup()),
+		zap.String("requestId", req.Header.Get(servers.SERVER_INCOME_REQUEST_ID)),
+		zap.Namespace("properties"),
+		zap.String("router", name),
+		zap.String("method", req.Method),
+		zap.String("url", u.String()),
+		zap.Int("attempt", attempt),
+		zap.Duration("time", duration),
+	}
+	// chunked 等长度未知时 ContentLength 为 -1, 不记录
+	if req.ContentLength >= 0 {
+		fields = append(fields, zap.Int64("requestSize", req.ContentLength))
+	}
+	if err != nil {
+		logger.Error("request", append(fields, zap.String("error", err.Error()))...)
+		return
+	}
+	fields = append(fields, zap.Int("status", resp.StatusCode))
+	if resp.ContentLength >= 0 {
+		fields = append(fields, zap.Int64("responseSize", resp.ContentLength))
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		logger.Warn("request", fields...)
+		return
+	}
+	logger.Info("request", fields...)
+}
+
+// requestContext 请求没有 ctx 时使用 Background
+func requestContext(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
@@ -1,10 +1,21 @@
 package main
 
 import (
+	_ "github.com/legenove/nano-server-sdk/asynccore"
+	_ "github.com/legenove/nano-server-sdk/croncore"
+	_ "github.com/legenove/nano-server-sdk/delay_queue"
 	_ "github.com/legenove/nano-server-sdk/gincore"
 	_ "github.com/legenove/nano-server-sdk/grpccore"
+	_ "github.com/legenove/nano-server-sdk/http_client"
+	_ "github.com/legenove/nano-server-sdk/mysql_client"
+	_ "github.com/legenove/nano-server-sdk/mysql_migrate"
+	_ "github.com/legenove/nano-server-sdk/redis_cache"
 	_ "github.com/legenove/nano-server-sdk/redis_client"
+	_ "github.com/legenove/nano-server-sdk/redis_leader"
+	_ "github.com/legenove/nano-server-sdk/redis_lock"
 	_ "github.com/legenove/nano-server-sdk/servers"
+	_ "github.com/legenove/nano-server-sdk/stacktrace"
+	_ "github.com/legenove/nano-server-sdk/subcore"
 )
 
 func main() {
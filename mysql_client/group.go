@@ -0,0 +1,135 @@
+package mysql_client
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+)
+
+// dbPools 逻辑库使用的链接池, 热更新时随路由一起重建
+type dbPools struct {
+	master   *sql.DB
+	replicas []*sql.DB
+}
+
+// DB 读写分离的逻辑库
+// 读请求轮询 slaver, 写请求与事务使用 master, ctx 中有 WithTx 开启的事务时所有请求都在事务中执行
+// 每次请求都从 Manager 获取链接池, 配置热更新后使用新的链接池
+type DB struct {
+	name string
+	next uint32
+	last atomic.Value // *dbPools, 路由被移除后继续使用最近一次的链接池
+}
+
+func GetMysqlDB(key string) (*DB, error) {
+	return Manager.GetMysqlDB(key)
+}
+
+// GetMysqlDB 获取逻辑库, master 与 slaver 类型的路由返回只包含自身的逻辑库
+// 同一个路由返回同一个 *DB, 可以长期持有
+func (m *mangers) GetMysqlDB(key string) (*DB, error) {
+	m.Lock()
+	defer m.Unlock()
+	name, pools, err := m.getPools(key)
+	if err != nil {
+		return nil, err
+	}
+	if db, ok := m.handles[name]; ok {
+		return db, nil
+	}
+	db := &DB{name: name}
+	db.last.Store(pools)
+	m.handles[name] = db
+	return db, nil
+}
+
+// getPools 调用方需持有锁
+func (m *mangers) getPools(key string) (string, *dbPools, error) {
+	setting, err := getMysqlConf(key)
+	if err != nil {
+		return "", nil, err
+	}
+	if pools, ok := m.groups[setting.RouterName]; ok {
+		return setting.RouterName, pools, nil
+	}
+	pools := &dbPools{}
+	if setting.Type != MysqlTypeGroup {
+		pools.master, err = m.getMysqlClient(setting.RouterName)
+		if err != nil {
+			return "", nil, err
+		}
+	} else {
+		pools.master, err = m.getMysqlClient(setting.GetMaster())
+		if err != nil {
+			return "", nil, err
+		}
+		for _, slaver := range setting.GetSlavers() {
+			replica, err := m.getMysqlClient(slaver)
+			if err != nil {
+				return "", nil, err
+			}
+			pools.replicas = append(pools.replicas, replica)
+		}
+	}
+	m.groups[setting.RouterName] = pools
+	return setting.RouterName, pools, nil
+}
+
+func (db *DB) pools() *dbPools {
+	Manager.Lock()
+	_, pools, err := Manager.getPools(db.name)
+	Manager.Unlock()
+	if err != nil {
+		return db.last.Load().(*dbPools)
+	}
+	db.last.Store(pools)
+	return pools
+}
+
+func (db *DB) Name() string {
+	return db.name
+}
+
+// Master 写库
+func (db *DB) Master() *sql.DB {
+	return db.pools().master
+}
+
+// Replica 轮询选择一个读库, 没有 slaver 时返回 master
+func (db *DB) Replica() *sql.DB {
+	pools := db.pools()
+	if len(pools.replicas) == 0 {
+		return pools.master
+	}
+	n := atomic.AddUint32(&db.next, 1)
+	return pools.replicas[int(n)%len(pools.replicas)]
+}
+
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if tx := txFromContext(ctx, db); tx != nil {
+		return tx.ExecContext(ctx, query, args...)
+	}
+	return db.Master().ExecContext(ctx, query, args...)
+}
+
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if tx := txFromContext(ctx, db); tx != nil {
+		return tx.QueryContext(ctx, query, args...)
+	}
+	return db.Replica().QueryContext(ctx, query, args...)
+}
+
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	if tx := txFromContext(ctx, db); tx != nil {
+		return tx.QueryRowContext(ctx, query, args...)
+	}
+	return db.Replica().QueryRowContext(ctx, query, args...)
+}
+
+// QueryMasterContext 在 master 上查询, 用于写后立即读等不能容忍主从延迟的场景
+func (db *DB) QueryMasterContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if tx := txFromContext(ctx, db); tx != nil {
+		return tx.QueryContext(ctx, query, args...)
+	}
+	return db.Master().QueryContext(ctx, query, args...)
+}
@@ -0,0 +1,264 @@
+package mysql_client
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/legenove/cocore"
+	"github.com/legenove/nano-server-sdk/servers"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/metadata"
+)
+
+// SlowLogThreshold 慢查询阈值，超过阈值的查询始终写入 mysql 日志
+// 通过 app 配置 MYSQL_SLOW_LOG_MS 设置，默认 200 毫秒
+var SlowLogThreshold = 200 * time.Millisecond
+
+func initMysqlLog() {
+	ms, err := strconv.Atoi(cocore.App.GetStringConfig("MYSQL_SLOW_LOG_MS", "200"))
+	if err != nil || ms <= 0 {
+		ms = 200
+	}
+	SlowLogThreshold = time.Duration(ms) * time.Millisecond
+}
+
+// logConnector 包装驱动的 Connector，所有链接的查询写入 mysql 日志
+type logConnector struct {
+	driver.Connector
+	name    string
+	logArgs bool
+}
+
+func (c *logConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &logConn{Conn: conn, connector: c}, nil
+}
+
+type logConn struct {
+	driver.Conn
+	connector *logConnector
+}
+
+func (c *logConn) log(ctx context.Context, query string, args []driver.NamedValue, rows int64, start time.Time, err error) {
+	if err == driver.ErrSkip {
+		// 驱动不支持直接执行，database/sql 会改为 prepare 后执行，由 logStmt 记录
+		return
+	}
+	logQuery(ctx, c.connector, query, args, rows, time.Since(start), err)
+}
+
+func (c *logConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	res, err := execer.ExecContext(ctx, query, args)
+	c.log(ctx, query, args, rowsAffected(res), start, err)
+	return res, err
+}
+
+func (c *logConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	c.log(ctx, query, args, -1, start, err)
+	return rows, err
+}
+
+func (c *logConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &logStmt{Stmt: stmt, conn: c, query: query}, nil
+}
+
+func (c *logConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *logConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	start := time.Now()
+	var tx driver.Tx
+	var err error
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = b.BeginTx(ctx, opts)
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	c.log(ctx, "BEGIN", nil, -1, start, err)
+	if err != nil {
+		return nil, err
+	}
+	return &logTx{Tx: tx, conn: c, ctx: ctx}, nil
+}
+
+func (c *logConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *logConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *logConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *logConn) CheckNamedValue(v *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(v)
+	}
+	return driver.ErrSkip
+}
+
+type logStmt struct {
+	driver.Stmt
+	conn  *logConn
+	query string
+}
+
+func (s *logStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var res driver.Result
+	var err error
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = e.ExecContext(ctx, args)
+	} else {
+		res, err = s.Stmt.Exec(namedValues(args))
+	}
+	s.conn.log(ctx, s.query, args, rowsAffected(res), start, err)
+	return res, err
+}
+
+func (s *logStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var rows driver.Rows
+	var err error
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = q.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(namedValues(args))
+	}
+	s.conn.log(ctx, s.query, args, -1, start, err)
+	return rows, err
+}
+
+type logTx struct {
+	driver.Tx
+	conn *logConn
+	ctx  context.Context
+}
+
+func (t *logTx) Commit() error {
+	start := time.Now()
+	err := t.Tx.Commit()
+	t.conn.log(t.ctx, "COMMIT", nil, -1, start, err)
+	return err
+}
+
+func (t *logTx) Rollback() error {
+	start := time.Now()
+	err := t.Tx.Rollback()
+	t.conn.log(t.ctx, "ROLLBACK", nil, -1, start, err)
+	return err
+}
+
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
+
+func rowsAffected(res driver.Result) int64 {
+	if res == nil {
+		return -1
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// logQuery DBDebugLog 开启时记录所有查询，否则只记录慢查询与出错的查询
+// 参数默认不记录，配置 LogArgs 后记录
+func logQuery(ctx context.Context, c *logConnector, query string, args []driver.NamedValue, rows int64, duration time.Duration, err error) {
+	slow := duration >= SlowLogThreshold
+	if !slow && err == nil && !servers.Server.DBDebugLog {
+		return
+	}
+	logger, lerr := mysqlLogger()
+	if lerr != nil {
+		return
+	}
+	// 没有请求上下文的查询不生成请求 id
+	var requestId string
+	if _, ok := metadata.FromIncomingContext(ctx); ok {
+		requestId = servers.GetRequestId(ctx)
+	}
+	fields := []zap.Field{
+		zap.String("requestId", requestId),
+		zap.Namespace("properties"),
+		zap.String("router", c.name),
+		zap.String("sql", query),
+		zap.Strings("args", formatArgs(args, c.logArgs)),
+		zap.Bool("slow", slow),
+		zap.Duration("time", duration),
+	}
+	if rows >= 0 {
+		fields = append(fields, zap.Int64("rowsAffected", rows))
+	}
+	if err != nil {
+		fields = append(fields, zap.String("error", err.Error()))
+	}
+	if err != nil || slow {
+		logger.Warn("mysql", fields...)
+	} else {
+		logger.Info("mysql", fields...)
+	}
+}
+
+// formatArgs 不记录参数时以 ? 代替，只保留参数个数
+func formatArgs(args []driver.NamedValue, logArgs bool) []string {
+	res := make([]string, len(args))
+	for i, arg := range args {
+		if !logArgs {
+			res[i] = "?"
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case []byte:
+			res[i] = string(v)
+		case time.Time:
+			res[i] = v.Format(time.RFC3339Nano)
+		default:
+			res[i] = fmt.Sprint(v)
+		}
+	}
+	return res
+}
@@ -0,0 +1,117 @@
+package mysql_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/legenove/nano-server-sdk/servers"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc/metadata"
+)
+
+func observeMysqlLog(t *testing.T) *observer.ObservedLogs {
+	core, logs := observer.New(zap.InfoLevel)
+	old := mysqlLogger
+	mysqlLogger = func() (*zap.Logger, error) { return zap.New(core), nil }
+	t.Cleanup(func() { mysqlLogger = old })
+	return logs
+}
+
+func properties(e observer.LoggedEntry) map[string]interface{} {
+	props, _ := e.ContextMap()["properties"].(map[string]interface{})
+	return props
+}
+
+func loggedSQL(logs *observer.ObservedLogs) []string {
+	var res []string
+	for _, e := range logs.TakeAll() {
+		res = append(res, fmt.Sprint(properties(e)["sql"]))
+	}
+	return res
+}
+
+func TestHookSlowLog(t *testing.T) {
+	logs := observeMysqlLog(t)
+	defer func(d time.Duration) { SlowLogThreshold = d }(SlowLogThreshold)
+	SlowLogThreshold = 0
+	db := registerTestDB(t, "hook_slow_test", &fakeConnector{})
+	ctx := context.Background()
+
+	db.ExecContext(ctx, "update a")
+	rows, err := db.QueryContext(ctx, "select a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	db.WithTx(ctx, func(ctx context.Context, tx *Tx) error {
+		_, err := db.ExecContext(ctx, "update b")
+		return err
+	})
+	expected := []string{"update a", "select a", "BEGIN", "update b", "COMMIT"}
+	entries := logs.All()
+	if q := loggedSQL(logs); !reflect.DeepEqual(q, expected) {
+		t.Fatalf("slow queries should be logged, got %v", q)
+	}
+	for _, e := range entries {
+		if properties(e)["slow"] != true {
+			t.Errorf("%v should be marked slow", properties(e)["sql"])
+		}
+	}
+}
+
+func TestHookErrorLog(t *testing.T) {
+	logs := observeMysqlLog(t)
+	defer func(d time.Duration) { SlowLogThreshold = d }(SlowLogThreshold)
+	SlowLogThreshold = time.Hour
+	errFailed := errors.New("failed")
+	db := registerTestDB(t, "hook_error_test", &fakeConnector{execErr: func(query string) error {
+		if query == "update err" {
+			return errFailed
+		}
+		return nil
+	}})
+	ctx := context.Background()
+
+	db.ExecContext(ctx, "update ok")
+	db.ExecContext(ctx, "update err")
+	db.WithTx(ctx, func(ctx context.Context, tx *Tx) error {
+		_, err := db.ExecContext(ctx, "update err")
+		return err
+	})
+	entries := logs.All()
+	if q := loggedSQL(logs); !reflect.DeepEqual(q, []string{"update err", "update err"}) {
+		t.Fatalf("only failed queries should be logged, got %v", q)
+	}
+	for _, e := range entries {
+		if properties(e)["error"] != "failed" {
+			t.Errorf("error should be logged, got %v", properties(e))
+		}
+	}
+}
+
+func TestHookRequestId(t *testing.T) {
+	logs := observeMysqlLog(t)
+	defer func(d time.Duration) { SlowLogThreshold = d }(SlowLogThreshold)
+	SlowLogThreshold = 0
+	db := registerTestDB(t, "hook_request_test", &fakeConnector{})
+
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(servers.SERVER_INCOME_REQUEST_ID, "hook-request"))
+	db.ExecContext(ctx, "update a")
+	db.ExecContext(context.Background(), "update b")
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("unexpected logs: %v", loggedSQL(logs))
+	}
+	if id := entries[0].ContextMap()["requestId"]; id != "hook-request" {
+		t.Errorf("request id should come from ctx, got %v", id)
+	}
+	if id := entries[1].ContextMap()["requestId"]; id != "" {
+		t.Errorf("query without request ctx should not log a request id, got %v", id)
+	}
+}
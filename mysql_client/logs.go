@@ -0,0 +1,20 @@
+package mysql_client
+
+import (
+	"github.com/legenove/nano-server-sdk/servers"
+	"go.uber.org/zap"
+)
+
+// mysqlLogger 返回写入 mysql 日志流的 logger, 测试时替换
+var mysqlLogger = func() (*zap.Logger, error) {
+	logger, err := servers.LogInstance(servers.LogDirMysql)
+	if err != nil {
+		return nil, err
+	}
+	return logger.With(
+		zap.String("log_type", servers.LOG_TYPE_MYSQL),
+		zap.String("event", servers.LogEventMysql),
+		zap.String("logServer", servers.Server.GetServerName()),
+		zap.String("logServerGroup", servers.Server.GetServerGroup()),
+	), nil
+}
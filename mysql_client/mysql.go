@@ -0,0 +1,134 @@
+package mysql_client
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/legenove/cocore"
+	"github.com/legenove/viper_conf"
+)
+
+type mangers struct {
+	dbs     map[string]*sql.DB
+	groups  map[string]*dbPools
+	handles map[string]*DB // GetMysqlDB 返回的逻辑库, 热更新时不移除
+	sync.Mutex
+}
+
+var mysqlConf *viper_conf.ViperConf
+var Manager = newManager()
+var mysqlSettings = make(map[string]*MysqlSetting)
+
+// 加载时的原始配置，热更新时使用原始配置对比
+var mysqlRawSettings = make(map[string]MysqlSetting)
+
+func newManager() *mangers {
+	return &mangers{
+		dbs:     make(map[string]*sql.DB),
+		groups:  make(map[string]*dbPools),
+		handles: make(map[string]*DB),
+	}
+}
+
+func getMysqlConf(key string) (*MysqlSetting, error) {
+	s, ok := mysqlSettings[key]
+	if ok {
+		return s, nil
+	}
+	if mysqlConf == nil {
+		err := newMysqlConfig()
+		if err != nil {
+			return nil, err
+		}
+		if mysqlConf == nil {
+			return nil, fmt.Errorf("mysql conf not setting")
+		}
+	}
+	var setting MysqlSetting
+	err := mysqlConf.GetConf().UnmarshalKey(key, &setting)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid mysql conf:%s; err:%s", key, err.Error())
+	}
+	if setting.RouterName == "" {
+		setting.RouterName = key
+	}
+	if err := setting.Validate(); err != nil {
+		return nil, err
+	}
+	mysqlSettings[setting.RouterName] = &setting
+	if _, ok := mysqlRawSettings[setting.RouterName]; !ok {
+		mysqlRawSettings[setting.RouterName] = setting
+	}
+	return &setting, nil
+}
+
+func newMysqlConfig() error {
+	var err error
+	mysqlFileName := cocore.App.GetStringConfig("mysql_conf", "mysql.toml")
+	mysqlConf, err = cocore.Conf.Instance(mysqlFileName, nil)
+	initMysqlLog()
+	cocore.RegisterInitFunc("mysqlLog", initMysqlLog)
+	go listenOnMysqlChange(mysqlConf)
+	return err
+}
+
+func listenOnMysqlChange(v *viper_conf.ViperConf) {
+	if v != nil {
+		<-v.OnChange
+		for {
+			select {
+			case <-v.OnChange:
+				reloadMysql()
+			}
+		}
+	}
+}
+
+func GetMysqlClient(key string) (*sql.DB, error) {
+	return Manager.GetMysqlClient(key)
+}
+
+func (m *mangers) GetMysqlClient(key string) (*sql.DB, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.getMysqlClient(key)
+}
+
+// getMysqlClient 调用方需持有锁
+func (m *mangers) getMysqlClient(key string) (*sql.DB, error) {
+	setting, err := getMysqlConf(key)
+	if err != nil {
+		return nil, err
+	}
+	if setting.Type == MysqlTypeGroup {
+		return nil, fmt.Errorf("%s : mysql client type is group, use GetMysqlDB", setting.RouterName)
+	}
+	db, ok := m.dbs[setting.RouterName]
+	if !ok {
+		db, err = newMysqlClient(setting)
+		if err != nil {
+			return nil, err
+		}
+		m.dbs[setting.RouterName] = db
+	}
+	return db, nil
+}
+
+// newMysqlClient 创建链接池, 不会建立链接, 第一次使用时才会连接
+func newMysqlClient(setting *MysqlSetting) (*sql.DB, error) {
+	cfg, err := mysql.ParseDSN(setting.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("mysql client can't be created, router: %s, err: %s", setting.RouterName, err.Error())
+	}
+	connector, err := mysql.NewConnector(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("mysql client can't be created, router: %s, err: %s", setting.RouterName, err.Error())
+	}
+	db := sql.OpenDB(&logConnector{Connector: connector, name: setting.RouterName, logArgs: setting.LogArgs})
+	db.SetMaxOpenConns(setting.GetMaxOpenConns())
+	db.SetMaxIdleConns(setting.GetMaxIdleConns())
+	db.SetConnMaxLifetime(setting.GetConnMaxLifetime())
+	return db, nil
+}
@@ -0,0 +1,125 @@
+package mysql_client
+
+import (
+	"database/sql"
+	"reflect"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// CloseGracePeriod 热更新后旧链接池延迟关闭的时间，等待执行中的查询与事务完成
+// 需要长期持有时使用 GetMysqlDB 返回的 *DB, 不要缓存 GetMysqlClient 返回的 *sql.DB
+var CloseGracePeriod = 30 * time.Second
+
+// detach 将路由从 Manager 中移除，调用方需持有锁
+func (m *mangers) detach(name string, stale []*sql.DB) []*sql.DB {
+	if db, ok := m.dbs[name]; ok {
+		stale = append(stale, db)
+		delete(m.dbs, name)
+	}
+	// group 不持有自己的链接池，只需要移除, *DB 下次请求时重新获取
+	delete(m.groups, name)
+	delete(mysqlSettings, name)
+	delete(mysqlRawSettings, name)
+	return stale
+}
+
+// reloadMysql 配置变更时只重建发生变化的路由，未变化的链接池继续使用
+// 配置解析失败时保留之前的配置
+func reloadMysql() {
+	conf := mysqlConf.GetConf()
+	if conf == nil {
+		logMysqlReload(nil, nil, nil, nil, mysqlConf.Error)
+		return
+	}
+	newSettings := make(map[string]MysqlSetting)
+	for key := range conf.AllSettings() {
+		var setting MysqlSetting
+		if err := conf.UnmarshalKey(key, &setting); err != nil {
+			logMysqlReload(nil, nil, nil, nil, err)
+			return
+		}
+		if setting.RouterName == "" {
+			setting.RouterName = key
+		}
+		if err := setting.Validate(); err != nil {
+			logMysqlReload(nil, nil, nil, nil, err)
+			return
+		}
+		newSettings[setting.RouterName] = setting
+	}
+
+	Manager.Lock()
+	defer Manager.Unlock()
+	var added, changed, removed, unchanged []string
+	dirty := make(map[string]bool)
+	for name, old := range mysqlRawSettings {
+		setting, ok := newSettings[name]
+		if !ok {
+			removed = append(removed, name)
+			dirty[name] = true
+		} else if !reflect.DeepEqual(old, setting) {
+			changed = append(changed, name)
+			dirty[name] = true
+		}
+	}
+	for name := range newSettings {
+		if _, ok := mysqlRawSettings[name]; !ok {
+			added = append(added, name)
+		}
+	}
+	// group 依赖的主从发生变化时需要一起重建
+	for name, old := range mysqlRawSettings {
+		if dirty[name] || old.Type != MysqlTypeGroup {
+			continue
+		}
+		depends := dirty[old.GetMaster()]
+		for _, slaver := range old.GetSlavers() {
+			depends = depends || dirty[slaver]
+		}
+		if depends {
+			changed = append(changed, name)
+			dirty[name] = true
+		}
+	}
+	for name := range mysqlRawSettings {
+		if !dirty[name] {
+			unchanged = append(unchanged, name)
+		}
+	}
+
+	var stale []*sql.DB
+	for name := range dirty {
+		stale = Manager.detach(name, stale)
+	}
+	time.AfterFunc(CloseGracePeriod, func() {
+		for _, db := range stale {
+			db.Close()
+		}
+	})
+	logMysqlReload(added, changed, removed, unchanged, nil)
+}
+
+func logMysqlReload(added, changed, removed, unchanged []string, err error) {
+	logger, lerr := mysqlLogger()
+	if lerr != nil {
+		return
+	}
+	if err != nil {
+		logger.Error("reload",
+			zap.Namespace("properties"),
+			zap.String("reason", "mysql conf parse failed, keep previous conf"),
+			zap.Error(err),
+		)
+		return
+	}
+	logger.Info("reload",
+		zap.Namespace("properties"),
+		zap.Strings("added", added),
+		zap.Strings("changed", changed),
+		zap.Strings("removed", removed),
+		zap.Strings("unchanged", unchanged),
+		zap.Duration("closeGracePeriod", CloseGracePeriod),
+	)
+}
@@ -0,0 +1,165 @@
+package mysql_client
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	MysqlTypeMaster = "master"
+	MysqlTypeSlaver = "slaver"
+	MysqlTypeGroup  = "group" // 读写分离, 由一个 master 和多个 slaver 组成
+)
+
+type MysqlSetting struct {
+	RouterName      string
+	Type            string
+	Host            string
+	Port            int // 默认 3306
+	User            string
+	Password        string
+	Database        string
+	Charset         string // 默认 utf8mb4
+	Collation       string
+	Loc             string            // 时区, 默认 Local
+	Params          map[string]string // 其他 DSN 参数
+	MaxOpenConns    int               // 链接池最大数量, 默认 100
+	MaxIdleConns    int               // 最大空闲链接数量, 默认 10
+	ConnMaxLifetime int               // 链接最长使用时间, 秒, 默认 1h
+	DialTimeout     int               // 创建链接超时, 毫秒, 默认 5000
+	ReadTimeout     int               // 读超时, 毫秒, 默认不超时
+	WriteTimeout    int               // 写超时, 毫秒, 默认不超时
+	LogArgs         bool              // 日志中记录 sql 参数, 默认以 ? 代替
+	Master          string            // group 使用的 master 路由名
+	Slavers         []string          // group 使用的 slaver 路由名列表, 为空时读也使用 master
+}
+
+// Validate 校验配置，错误信息中包含路由名
+func (s *MysqlSetting) Validate() error {
+	switch s.Type {
+	case MysqlTypeMaster, MysqlTypeSlaver:
+		if s.Host == "" {
+			return fmt.Errorf("mysql conf %s: Host is required for type %s", s.RouterName, s.Type)
+		}
+		if s.User == "" {
+			return fmt.Errorf("mysql conf %s: User is required for type %s", s.RouterName, s.Type)
+		}
+		if s.Database == "" {
+			return fmt.Errorf("mysql conf %s: Database is required for type %s", s.RouterName, s.Type)
+		}
+	case MysqlTypeGroup:
+		if s.Master == "" {
+			return fmt.Errorf("mysql conf %s: Master is required for type group", s.RouterName)
+		}
+	default:
+		return fmt.Errorf("mysql conf %s: type not support: %s", s.RouterName, s.Type)
+	}
+	if s.Port < 0 || s.Port > 65535 {
+		return fmt.Errorf("mysql conf %s: Port out of range: %d", s.RouterName, s.Port)
+	}
+	if s.Loc != "" {
+		if _, err := time.LoadLocation(s.Loc); err != nil {
+			return fmt.Errorf("mysql conf %s: invalid Loc: %s", s.RouterName, err.Error())
+		}
+	}
+	return nil
+}
+
+func (s *MysqlSetting) GetReadOnly() bool {
+	return s.Type == MysqlTypeSlaver
+}
+
+func (s *MysqlSetting) GetMaster() string {
+	return s.Master
+}
+
+func (s *MysqlSetting) GetSlavers() []string {
+	return s.Slavers
+}
+
+func (s *MysqlSetting) GetAddr() string {
+	port := s.Port
+	if port == 0 {
+		port = 3306
+	}
+	return net.JoinHostPort(s.Host, strconv.Itoa(port))
+}
+
+func (s *MysqlSetting) GetCharset() string {
+	if s.Charset == "" {
+		return "utf8mb4"
+	}
+	return s.Charset
+}
+
+func (s *MysqlSetting) GetLoc() *time.Location {
+	if s.Loc == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(s.Loc)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+func (s *MysqlSetting) GetMaxOpenConns() int {
+	if s.MaxOpenConns <= 0 {
+		return 100
+	}
+	return s.MaxOpenConns
+}
+
+func (s *MysqlSetting) GetMaxIdleConns() int {
+	if s.MaxIdleConns <= 0 {
+		return 10
+	}
+	return s.MaxIdleConns
+}
+
+func (s *MysqlSetting) GetConnMaxLifetime() time.Duration {
+	if s.ConnMaxLifetime <= 0 {
+		return time.Hour
+	}
+	return time.Duration(s.ConnMaxLifetime) * time.Second
+}
+
+func (s *MysqlSetting) GetDialTimeout() time.Duration {
+	if s.DialTimeout <= 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(s.DialTimeout) * time.Millisecond
+}
+
+func (s *MysqlSetting) GetReadTimeout() time.Duration {
+	return time.Duration(s.ReadTimeout) * time.Millisecond
+}
+
+func (s *MysqlSetting) GetWriteTimeout() time.Duration {
+	return time.Duration(s.WriteTimeout) * time.Millisecond
+}
+
+// DSN 由配置拼接 go-sql-driver/mysql 的连接串
+func (s *MysqlSetting) DSN() string {
+	cfg := mysql.NewConfig()
+	cfg.Net = "tcp"
+	cfg.Addr = s.GetAddr()
+	cfg.User = s.User
+	cfg.Passwd = s.Password
+	cfg.DBName = s.Database
+	cfg.Collation = s.Collation
+	cfg.Loc = s.GetLoc()
+	cfg.ParseTime = true
+	cfg.Timeout = s.GetDialTimeout()
+	cfg.ReadTimeout = s.GetReadTimeout()
+	cfg.WriteTimeout = s.GetWriteTimeout()
+	cfg.Params = map[string]string{"charset": s.GetCharset()}
+	for k, v := range s.Params {
+		cfg.Params[k] = v
+	}
+	return cfg.FormatDSN()
+}
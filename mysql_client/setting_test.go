@@ -0,0 +1,51 @@
+package mysql_client
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMysqlSettingValidate(t *testing.T) {
+	cases := []struct {
+		setting MysqlSetting
+		err     string
+	}{
+		{MysqlSetting{RouterName: "a", Type: MysqlTypeMaster, Host: "127.0.0.1", User: "root", Database: "test"}, ""},
+		{MysqlSetting{RouterName: "b", Type: "unknown"}, "mysql conf b: type not support"},
+		{MysqlSetting{RouterName: "c", Type: MysqlTypeMaster, User: "root", Database: "test"}, "mysql conf c: Host is required"},
+		{MysqlSetting{RouterName: "d", Type: MysqlTypeSlaver, Host: "x", User: "root"}, "mysql conf d: Database is required"},
+		{MysqlSetting{RouterName: "e", Type: MysqlTypeMaster, Host: "x", User: "root", Database: "test", Loc: "Nowhere/City"}, "mysql conf e: invalid Loc"},
+		{MysqlSetting{RouterName: "f", Type: MysqlTypeGroup, Slavers: []string{"s"}}, "mysql conf f: Master is required"},
+		{MysqlSetting{RouterName: "g", Type: MysqlTypeGroup, Master: "m"}, ""},
+	}
+	for _, c := range cases {
+		err := c.setting.Validate()
+		if c.err == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %s", c.setting.RouterName, err)
+			}
+			continue
+		}
+		if err == nil || !strings.HasPrefix(err.Error(), c.err) {
+			t.Errorf("%s: expected error %q, got %v", c.setting.RouterName, c.err, err)
+		}
+	}
+}
+
+func TestMysqlSettingDSN(t *testing.T) {
+	s := &MysqlSetting{
+		Host:        "db.local",
+		User:        "app",
+		Password:    "secret",
+		Database:    "orders",
+		Loc:         "UTC",
+		ReadTimeout: 3000,
+		Params:      map[string]string{"sql_mode": "'STRICT_ALL_TABLES'"},
+	}
+	dsn := s.DSN()
+	for _, part := range []string{"app:secret@tcp(db.local:3306)/orders?", "charset=utf8mb4", "parseTime=true", "readTimeout=3s", "sql_mode="} {
+		if !strings.Contains(dsn, part) {
+			t.Errorf("dsn %q should contain %q", dsn, part)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package mysql_client
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// 死锁与锁等待超时, 重试整个事务
+const (
+	errDeadlock        = 1213
+	errLockWaitTimeout = 1205
+)
+
+// TxMaxRetry 事务遇到死锁时的最大重试次数
+var TxMaxRetry = 3
+
+type txKey struct {
+	db *DB
+}
+
+// Tx WithTx 开启的事务, 嵌套调用 WithTx 时使用 savepoint
+type Tx struct {
+	*sql.Tx
+	savepoints int
+}
+
+func txFromContext(ctx context.Context, db *DB) *Tx {
+	tx, _ := ctx.Value(txKey{db}).(*Tx)
+	return tx
+}
+
+// WithTx 在事务中执行 fn, fn 返回错误或 panic 时回滚, 否则提交
+// fn 中使用 ctx 调用 db 的方法会在同一个事务中执行
+// 已在事务中时使用 savepoint, 只回滚 fn 中的修改
+// 最外层事务遇到死锁时整体重试, fn 需要可重复执行
+func (db *DB) WithTx(ctx context.Context, fn func(ctx context.Context, tx *Tx) error, opts ...*sql.TxOptions) error {
+	if tx := txFromContext(ctx, db); tx != nil {
+		return tx.withSavepoint(ctx, fn)
+	}
+	var opt *sql.TxOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	var err error
+	for i := 0; ; i++ {
+		err = db.withTx(ctx, fn, opt)
+		if err == nil || i >= TxMaxRetry || !isDeadlock(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(i+1) * 10 * time.Millisecond):
+		}
+	}
+}
+
+func (db *DB) withTx(ctx context.Context, fn func(ctx context.Context, tx *Tx) error, opt *sql.TxOptions) (err error) {
+	sqlTx, err := db.Master().BeginTx(ctx, opt)
+	if err != nil {
+		return err
+	}
+	tx := &Tx{Tx: sqlTx}
+	defer func() {
+		if r := recover(); r != nil {
+			sqlTx.Rollback()
+			panic(r)
+		}
+	}()
+	if err = fn(context.WithValue(ctx, txKey{db}, tx), tx); err != nil {
+		sqlTx.Rollback()
+		return err
+	}
+	return sqlTx.Commit()
+}
+
+func (tx *Tx) withSavepoint(ctx context.Context, fn func(ctx context.Context, tx *Tx) error) (err error) {
+	tx.savepoints++
+	name := fmt.Sprintf("sp_%d", tx.savepoints)
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+			panic(r)
+		}
+	}()
+	if err = fn(ctx, tx); err != nil {
+		tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+	return err
+}
+
+func isDeadlock(err error) bool {
+	var e *mysql.MySQLError
+	if errors.As(err, &e) {
+		return e.Number == errDeadlock || e.Number == errLockWaitTimeout
+	}
+	return false
+}
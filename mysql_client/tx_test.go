@@ -0,0 +1,236 @@
+package mysql_client
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// fakeConnector 记录执行的语句, 不连接数据库
+type fakeConnector struct {
+	mu      sync.Mutex
+	queries []string
+	execErr func(query string) error
+}
+
+func (c *fakeConnector) record(query string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.queries = append(c.queries, query)
+}
+
+func (c *fakeConnector) take() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	q := c.queries
+	c.queries = nil
+	return q
+}
+
+func (c *fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{c: c}, nil
+}
+
+func (c *fakeConnector) Driver() driver.Driver {
+	return nil
+}
+
+type fakeConn struct {
+	c *fakeConnector
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.c.record("BEGIN")
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	c.c.record("COMMIT")
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.c.record("ROLLBACK")
+	return nil
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.c.record(query)
+	if c.c.execErr != nil {
+		if err := c.c.execErr(query); err != nil {
+			return nil, err
+		}
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.c.record(query)
+	return fakeRows{}, nil
+}
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string              { return nil }
+func (fakeRows) Close() error                   { return nil }
+func (fakeRows) Next(dest []driver.Value) error { return io.EOF }
+
+// registerTestDB 将 fake 链接池注册为 group 路由, 不需要配置文件, 查询与真实链接一样经过日志 hook
+func registerTestDB(t *testing.T, name string, master *fakeConnector, replicas ...*fakeConnector) *DB {
+	t.Helper()
+	Manager.Lock()
+	group := &MysqlSetting{RouterName: name, Type: MysqlTypeGroup, Master: name + "_master"}
+	register := func(router string, c *fakeConnector) {
+		mysqlSettings[router] = &MysqlSetting{RouterName: router, Type: MysqlTypeMaster}
+		Manager.dbs[router] = sql.OpenDB(&logConnector{Connector: c, name: router})
+	}
+	register(group.Master, master)
+	for i, c := range replicas {
+		router := fmt.Sprintf("%s_slaver%d", name, i)
+		group.Slavers = append(group.Slavers, router)
+		register(router, c)
+	}
+	mysqlSettings[name] = group
+	Manager.Unlock()
+	db, err := GetMysqlDB(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestWithTx(t *testing.T) {
+	master := &fakeConnector{}
+	db := registerTestDB(t, "tx_test", master)
+	ctx := context.Background()
+	errFailed := errors.New("failed")
+
+	err := db.WithTx(ctx, func(ctx context.Context, tx *Tx) error {
+		db.ExecContext(ctx, "insert a")
+		// 内层失败只回滚到 savepoint
+		err := db.WithTx(ctx, func(ctx context.Context, tx *Tx) error {
+			db.ExecContext(ctx, "insert b")
+			return errFailed
+		})
+		if err != errFailed {
+			t.Errorf("nested WithTx should return fn error, got %v", err)
+		}
+		return db.WithTx(ctx, func(ctx context.Context, tx *Tx) error {
+			_, err := db.ExecContext(ctx, "insert c")
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"BEGIN", "insert a", "SAVEPOINT sp_1", "insert b", "ROLLBACK TO SAVEPOINT sp_1",
+		"SAVEPOINT sp_2", "insert c", "RELEASE SAVEPOINT sp_2", "COMMIT"}
+	if q := master.take(); !reflect.DeepEqual(q, expected) {
+		t.Errorf("unexpected queries: %v", q)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("panic should be rethrown")
+			}
+		}()
+		db.WithTx(ctx, func(ctx context.Context, tx *Tx) error {
+			panic("boom")
+		})
+	}()
+	if q := master.take(); !reflect.DeepEqual(q, []string{"BEGIN", "ROLLBACK"}) {
+		t.Errorf("panic should rollback, got %v", q)
+	}
+}
+
+func TestWithTxDeadlockRetry(t *testing.T) {
+	var n int
+	master := &fakeConnector{execErr: func(query string) error {
+		if n++; n < 3 {
+			return &mysql.MySQLError{Number: errDeadlock, Message: "Deadlock found"}
+		}
+		return nil
+	}}
+	db := registerTestDB(t, "deadlock_test", master)
+	err := db.WithTx(context.Background(), func(ctx context.Context, tx *Tx) error {
+		_, err := db.ExecContext(ctx, "update a")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("should retry twice, executed %d times", n)
+	}
+}
+
+func TestDBRouting(t *testing.T) {
+	master, replica := &fakeConnector{}, &fakeConnector{}
+	db := registerTestDB(t, "routing_test", master, replica)
+	ctx := context.Background()
+	db.ExecContext(ctx, "update a")
+	rows, _ := db.QueryContext(ctx, "select a")
+	rows.Close()
+	if q := master.take(); !reflect.DeepEqual(q, []string{"update a"}) {
+		t.Errorf("writes should go to master, got %v", q)
+	}
+	if q := replica.take(); !reflect.DeepEqual(q, []string{"select a"}) {
+		t.Errorf("reads should go to replica, got %v", q)
+	}
+	db.WithTx(ctx, func(ctx context.Context, tx *Tx) error {
+		rows, err := db.QueryContext(ctx, "select b")
+		if err == nil {
+			rows.Close()
+		}
+		return err
+	})
+	if q := master.take(); !reflect.DeepEqual(q, []string{"BEGIN", "select b", "COMMIT"}) {
+		t.Errorf("reads in tx should go to master, got %v", q)
+	}
+}
+
+// 热更新替换链接池后, 之前获取的 *DB 使用新的链接池
+func TestDBAfterReload(t *testing.T) {
+	old, next := &fakeConnector{}, &fakeConnector{}
+	db := registerTestDB(t, "reload_test", old)
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, "update a"); err != nil {
+		t.Fatal(err)
+	}
+
+	Manager.Lock()
+	stale := Manager.detach("reload_test_master", nil)
+	stale = Manager.detach("reload_test", stale)
+	Manager.Unlock()
+	for _, s := range stale {
+		s.Close()
+	}
+	registerTestDB(t, "reload_test", next)
+
+	if _, err := db.ExecContext(ctx, "update b"); err != nil {
+		t.Fatalf("exec after reload: %v", err)
+	}
+	if q := next.take(); !reflect.DeepEqual(q, []string{"update b"}) {
+		t.Errorf("should use the new pool, got %v", q)
+	}
+	if again, _ := GetMysqlDB("reload_test"); again != db {
+		t.Error("GetMysqlDB should return the same handle")
+	}
+}
@@ -0,0 +1,84 @@
+package mysql_migrate
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// MigrateOnStartup 服务启动时执行所有未执行的迁移, 多个副本同时启动时只有一个副本执行
+//
+//	servers.InitServer(...)
+//	if err := mysql_migrate.MigrateOnStartup(ctx, "default_mysql", "./migrations"); err != nil {
+//		panic(err)
+//	}
+func MigrateOnStartup(ctx context.Context, routerName, dir string, opts ...Option) error {
+	return NewMigrator(routerName, dir, opts...).Up(ctx)
+}
+
+// Command 命令行入口, 由服务的 main 在解析到 migrate 子命令时调用
+// 需要先初始化 cocore 配置, mysql_client 才能读取路由配置
+//
+//	migrate [-router default_mysql] [-dir ./migrations] up|down [n]|status
+//
+//	func main() {
+//		servers.InitServer(...)
+//		if len(os.Args) > 1 && os.Args[1] == "migrate" {
+//			if err := mysql_migrate.Command(context.Background(), os.Args[2:]); err != nil {
+//				fmt.Fprintln(os.Stderr, err)
+//				os.Exit(1)
+//			}
+//			return
+//		}
+//		...
+//	}
+func Command(ctx context.Context, args []string, opts ...Option) error {
+	return command(ctx, args, os.Stdout, opts...)
+}
+
+func command(ctx context.Context, args []string, out io.Writer, opts ...Option) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.SetOutput(out)
+	router := fs.String("router", "default_mysql", "mysql_client router name")
+	dir := fs.String("dir", "./migrations", "migration files directory")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	m := NewMigrator(*router, *dir, opts...)
+	switch fs.Arg(0) {
+	case "up":
+		return m.Up(ctx)
+	case "down":
+		steps := 1
+		if fs.NArg() > 1 {
+			n, err := strconv.Atoi(fs.Arg(1))
+			if err != nil || n <= 0 {
+				return fmt.Errorf("mysql_migrate: invalid down steps: %s", fs.Arg(1))
+			}
+			steps = n
+		}
+		return m.Down(ctx, steps)
+	case "status":
+		status, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range status {
+			state := "pending"
+			if s.Dirty {
+				state = "dirty"
+			} else if s.Applied {
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(out, "%d\t%s\t%s\n", s.Version, s.Name, state)
+		}
+		return nil
+	default:
+		fs.Usage()
+		return fmt.Errorf("mysql_migrate: unknown command: %q, use up, down [n] or status", fs.Arg(0))
+	}
+}
@@ -0,0 +1,99 @@
+// 数据库迁移, 按版本号执行目录中的 sql 文件, 已执行的版本记录在 schema_migrations 表中
+// 文件名格式: <version>_<name>.up.sql 与 <version>_<name>.down.sql, 如 20200801120000_create_orders.up.sql
+package mysql_migrate
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var fileNameRegexp = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration 一个版本的迁移
+type Migration struct {
+	Version  int64
+	Name     string
+	UpFile   string
+	DownFile string
+}
+
+// loadMigrations 读取目录中的迁移文件, 按版本号升序返回
+func loadMigrations(dir string) ([]*Migration, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	versions := make(map[int64]*Migration)
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		m := fileNameRegexp.FindStringSubmatch(f.Name())
+		if m == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("mysql_migrate: invalid version in %s: %s", f.Name(), err.Error())
+		}
+		migration, ok := versions[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			versions[version] = migration
+		} else if migration.Name != m[2] {
+			return nil, fmt.Errorf("mysql_migrate: version %d has different names: %s, %s", version, migration.Name, m[2])
+		}
+		path := filepath.Join(dir, f.Name())
+		if m[3] == "up" {
+			migration.UpFile = path
+		} else {
+			migration.DownFile = path
+		}
+	}
+	migrations := make([]*Migration, 0, len(versions))
+	for _, migration := range versions {
+		if migration.UpFile == "" {
+			return nil, fmt.Errorf("mysql_migrate: version %d has no up file", migration.Version)
+		}
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+func readStatements(file string) ([]string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return splitStatements(string(data)), nil
+}
+
+// splitStatements 按行尾的分号拆分语句, 忽略空行与 -- 注释行
+// 语句中间的分号需要避免出现在行尾
+func splitStatements(content string) []string {
+	var statements []string
+	var buf []string
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		buf = append(buf, line)
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(strings.Join(buf, "\n")), ";"))
+			buf = nil
+		}
+	}
+	if len(buf) > 0 {
+		statements = append(statements, strings.TrimSpace(strings.Join(buf, "\n")))
+	}
+	return statements
+}
@@ -0,0 +1,53 @@
+package mysql_migrate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"2_add_index.up.sql", "1_create_orders.up.sql", "1_create_orders.down.sql", "README.md"} {
+		ioutil.WriteFile(filepath.Join(dir, name), nil, 0644)
+	}
+	migrations, err := loadMigrations(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 || migrations[0].Version != 1 || migrations[1].Version != 2 {
+		t.Fatalf("unexpected migrations: %+v", migrations)
+	}
+	if migrations[0].Name != "create_orders" || migrations[0].DownFile == "" || migrations[1].DownFile != "" {
+		t.Errorf("unexpected migration files: %+v, %+v", migrations[0], migrations[1])
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "3_only_down.down.sql"), nil, 0644)
+	if _, err := loadMigrations(dir); err == nil {
+		t.Error("version without up file should fail")
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	content := `-- create table
+CREATE TABLE orders (
+  id BIGINT PRIMARY KEY
+);
+
+INSERT INTO orders VALUES (1);
+UPDATE orders SET id = 2`
+	expected := []string{
+		"CREATE TABLE orders (\n  id BIGINT PRIMARY KEY\n)",
+		"INSERT INTO orders VALUES (1)",
+		"UPDATE orders SET id = 2",
+	}
+	if res := splitStatements(content); !reflect.DeepEqual(res, expected) {
+		t.Errorf("unexpected statements: %q", res)
+	}
+}
@@ -0,0 +1,245 @@
+package mysql_migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/legenove/nano-server-sdk/mysql_client"
+	"github.com/legenove/nano-server-sdk/servers"
+	"go.uber.org/zap"
+)
+
+// 测试时替换为 fake 的链接池
+var getMysqlClient = mysql_client.GetMysqlClient
+
+var ErrDirty = errors.New("mysql_migrate: database is dirty, fix the failed migration manually and delete its row")
+
+type options struct {
+	table       string
+	lockTimeout time.Duration
+}
+
+type Option func(*options)
+
+// WithTable 记录已执行版本的表名, 默认 schema_migrations
+func WithTable(table string) Option {
+	return func(o *options) {
+		o.table = table
+	}
+}
+
+// WithLockTimeout 等待其他副本迁移完成的时间, 默认 1min
+func WithLockTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.lockTimeout = d
+	}
+}
+
+// Migrator 将目录中的迁移应用到 mysql_client 的路由上
+type Migrator struct {
+	router string
+	dir    string
+	opt    options
+}
+
+func NewMigrator(routerName, dir string, opts ...Option) *Migrator {
+	opt := options{table: "schema_migrations", lockTimeout: time.Minute}
+	for _, o := range opts {
+		o(&opt)
+	}
+	return &Migrator{router: routerName, dir: dir, opt: opt}
+}
+
+// MigrationStatus 迁移的执行状态
+type MigrationStatus struct {
+	*Migration
+	Applied   bool
+	Dirty     bool
+	AppliedAt time.Time
+}
+
+type appliedVersion struct {
+	dirty     bool
+	appliedAt time.Time
+}
+
+// Up 执行所有未执行的迁移
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn, migrations []*Migration, applied map[int64]appliedVersion) error {
+		for _, migration := range migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Down 按版本从新到旧回滚 steps 个已执行的迁移
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func(conn *sql.Conn, migrations []*Migration, applied map[int64]appliedVersion) error {
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			migration := migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.DownFile == "" {
+				return fmt.Errorf("mysql_migrate: version %d has no down file", migration.Version)
+			}
+			if err := m.apply(ctx, conn, migration, false); err != nil {
+				return err
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// Status 返回目录中所有迁移的执行状态
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var res []MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn, migrations []*Migration, applied map[int64]appliedVersion) error {
+		for _, migration := range migrations {
+			v, ok := applied[migration.Version]
+			res = append(res, MigrationStatus{Migration: migration, Applied: ok, Dirty: v.dirty, AppliedAt: v.appliedAt})
+		}
+		return nil
+	}, true)
+	return res, err
+}
+
+// withLock 在同一个链接上持有 GET_LOCK 咨询锁, 保证只有一个副本执行迁移
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn, migrations []*Migration, applied map[int64]appliedVersion) error, allowDirty ...bool) error {
+	migrations, err := loadMigrations(m.dir)
+	if err != nil {
+		return err
+	}
+	db, err := getMysqlClient(m.router)
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	lockName := "nano_migrate:" + m.router + ":" + m.opt.table
+	var got sql.NullInt64
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, int(m.opt.lockTimeout/time.Second)).Scan(&got)
+	if err != nil {
+		return err
+	}
+	if !got.Valid || got.Int64 != 1 {
+		return fmt.Errorf("mysql_migrate: wait for lock %s timeout", lockName)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName)
+
+	if err := m.ensureTable(ctx, conn); err != nil {
+		return err
+	}
+	applied, err := m.appliedVersions(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if len(allowDirty) == 0 || !allowDirty[0] {
+		for version, v := range applied {
+			if v.dirty {
+				return fmt.Errorf("%w: version %d", ErrDirty, version)
+			}
+		}
+	}
+	return fn(conn, migrations, applied)
+}
+
+func (m *Migrator) ensureTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS `"+m.opt.table+"` ("+
+		"`version` BIGINT NOT NULL PRIMARY KEY, "+
+		"`name` VARCHAR(255) NOT NULL, "+
+		"`dirty` TINYINT(1) NOT NULL DEFAULT 0, "+
+		"`applied_at` DATETIME NOT NULL)")
+	return err
+}
+
+func (m *Migrator) appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]appliedVersion, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT `version`, `dirty`, `applied_at` FROM `"+m.opt.table+"`")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int64]appliedVersion)
+	for rows.Next() {
+		var version int64
+		var v appliedVersion
+		if err := rows.Scan(&version, &v.dirty, &v.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = v
+	}
+	return applied, rows.Err()
+}
+
+// apply 执行一个版本的 up 或 down 文件
+// mysql 的 DDL 无法回滚, 执行前将版本标记为 dirty, 失败时保留标记等待人工处理
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration *Migration, up bool) error {
+	file := migration.UpFile
+	direction := "up"
+	if !up {
+		file = migration.DownFile
+		direction = "down"
+	}
+	statements, err := readStatements(file)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	_, err = conn.ExecContext(ctx, "REPLACE INTO `"+m.opt.table+"` (`version`, `name`, `dirty`, `applied_at`) VALUES (?, ?, 1, ?)",
+		migration.Version, migration.Name, start)
+	if err != nil {
+		return err
+	}
+	for i, statement := range statements {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			err = fmt.Errorf("mysql_migrate: version %d %s statement %d failed: %w", migration.Version, direction, i+1, err)
+			m.log(migration, direction, time.Since(start), err)
+			return err
+		}
+	}
+	if up {
+		_, err = conn.ExecContext(ctx, "UPDATE `"+m.opt.table+"` SET `dirty` = 0 WHERE `version` = ?", migration.Version)
+	} else {
+		_, err = conn.ExecContext(ctx, "DELETE FROM `"+m.opt.table+"` WHERE `version` = ?", migration.Version)
+	}
+	m.log(migration, direction, time.Since(start), err)
+	return err
+}
+
+func (m *Migrator) log(migration *Migration, direction string, duration time.Duration, err error) {
+	logger, lerr := servers.LogInstance(servers.LogDirMysql)
+	if lerr != nil {
+		return
+	}
+	fields := []zap.Field{
+		zap.String("log_type", servers.LOG_TYPE_MYSQL),
+		zap.String("event", servers.LogEventMysql),
+		zap.String("logServer", servers.Server.GetServerName()),
+		zap.String("logServerGroup", servers.Server.GetServerGroup()),
+		zap.Namespace("properties"),
+		zap.String("router", m.router),
+		zap.Int64("version", migration.Version),
+		zap.String("name", migration.Name),
+		zap.String("direction", direction),
+		zap.Duration("time", duration),
+	}
+	if err != nil {
+		logger.Error("migrate", append(fields, zap.String("error", err.Error()))...)
+		return
+	}
+	logger.Info("migrate", fields...)
+}
@@ -0,0 +1,265 @@
+package mysql_migrate
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConnector 在内存中模拟 GET_LOCK 与 schema_migrations 表, 记录执行的语句
+type fakeConnector struct {
+	mu       sync.Mutex
+	queries  []string
+	lock     int64
+	versions map[int64]bool // version -> dirty
+	execErr  func(query string) error
+}
+
+func newFakeConnector() *fakeConnector {
+	return &fakeConnector{lock: 1, versions: map[int64]bool{}}
+}
+
+func (c *fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{c: c}, nil
+}
+
+func (c *fakeConnector) Driver() driver.Driver {
+	return nil
+}
+
+func (c *fakeConnector) take() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	q := c.queries
+	c.queries = nil
+	return q
+}
+
+type fakeConn struct {
+	c *fakeConnector
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.c.mu.Lock()
+	defer c.c.mu.Unlock()
+	c.c.queries = append(c.c.queries, query)
+	switch {
+	case strings.HasPrefix(query, "REPLACE INTO"):
+		c.c.versions[args[0].Value.(int64)] = true
+	case strings.HasPrefix(query, "UPDATE `schema_migrations`"):
+		c.c.versions[args[0].Value.(int64)] = false
+	case strings.HasPrefix(query, "DELETE FROM `schema_migrations`"):
+		delete(c.c.versions, args[0].Value.(int64))
+	case c.c.execErr != nil:
+		if err := c.c.execErr(query); err != nil {
+			return nil, err
+		}
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.c.mu.Lock()
+	defer c.c.mu.Unlock()
+	c.c.queries = append(c.c.queries, query)
+	if strings.HasPrefix(query, "SELECT GET_LOCK") {
+		return &fakeRows{columns: []string{"lock"}, values: [][]driver.Value{{c.c.lock}}}, nil
+	}
+	rows := &fakeRows{columns: []string{"version", "dirty", "applied_at"}}
+	for version, dirty := range c.c.versions {
+		rows.values = append(rows.values, []driver.Value{version, dirty, time.Now()})
+	}
+	return rows, nil
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func newTestMigrator(t *testing.T, c *fakeConnector, files map[string]string) *Migrator {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	for name, content := range files {
+		ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	}
+	db := sql.OpenDB(c)
+	t.Cleanup(func() { db.Close() })
+	old := getMysqlClient
+	getMysqlClient = func(string) (*sql.DB, error) { return db, nil }
+	t.Cleanup(func() { getMysqlClient = old })
+	return NewMigrator("migrate_test", dir)
+}
+
+// statements 去掉锁与 schema_migrations 相关的语句
+func statements(queries []string) []string {
+	var res []string
+	for _, q := range queries {
+		if strings.Contains(q, "_LOCK(") || strings.Contains(q, "`schema_migrations`") {
+			continue
+		}
+		res = append(res, q)
+	}
+	return res
+}
+
+var testMigrations = map[string]string{
+	"1_create_a.up.sql":   "CREATE TABLE a;",
+	"1_create_a.down.sql": "DROP TABLE a;",
+	"2_alter_a.up.sql":    "ALTER TABLE a ADD b;\nALTER TABLE a ADD c;",
+	"2_alter_a.down.sql":  "ALTER TABLE a DROP c;\nALTER TABLE a DROP b;",
+}
+
+func TestMigratorUpDown(t *testing.T) {
+	c := newFakeConnector()
+	m := newTestMigrator(t, c, testMigrations)
+	ctx := context.Background()
+	if err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	queries := c.take()
+	if !strings.HasPrefix(queries[0], "SELECT GET_LOCK") || !strings.HasPrefix(queries[len(queries)-1], "SELECT RELEASE_LOCK") {
+		t.Errorf("migrations should run under the lock, got %q", queries)
+	}
+	expected := []string{"CREATE TABLE a", "ALTER TABLE a ADD b", "ALTER TABLE a ADD c"}
+	if s := statements(queries); !reflect.DeepEqual(s, expected) {
+		t.Errorf("unexpected up statements: %q", s)
+	}
+	if !reflect.DeepEqual(c.versions, map[int64]bool{1: false, 2: false}) {
+		t.Errorf("unexpected versions after up: %v", c.versions)
+	}
+
+	// 已执行的版本不再执行
+	if err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s := statements(c.take()); len(s) != 0 {
+		t.Errorf("applied versions should be skipped, got %q", s)
+	}
+
+	if err := m.Down(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"ALTER TABLE a DROP c", "ALTER TABLE a DROP b"}
+	if s := statements(c.take()); !reflect.DeepEqual(s, expected) {
+		t.Errorf("unexpected down statements: %q", s)
+	}
+	if !reflect.DeepEqual(c.versions, map[int64]bool{1: false}) {
+		t.Errorf("unexpected versions after down: %v", c.versions)
+	}
+}
+
+// 部分语句执行失败后版本保持 dirty, 之后的迁移拒绝执行
+func TestMigratorDirty(t *testing.T) {
+	c := newFakeConnector()
+	errFailed := errors.New("failed")
+	c.execErr = func(query string) error {
+		if query == "ALTER TABLE a ADD b" {
+			return errFailed
+		}
+		return nil
+	}
+	m := newTestMigrator(t, c, testMigrations)
+	ctx := context.Background()
+	if err := m.Up(ctx); !errors.Is(err, errFailed) {
+		t.Fatalf("Up should return the statement error, got %v", err)
+	}
+	expected := []string{"CREATE TABLE a", "ALTER TABLE a ADD b"}
+	if s := statements(c.take()); !reflect.DeepEqual(s, expected) {
+		t.Errorf("statements after the failure should not run, got %q", s)
+	}
+	if !reflect.DeepEqual(c.versions, map[int64]bool{1: false, 2: true}) {
+		t.Errorf("failed version should be dirty: %v", c.versions)
+	}
+
+	c.execErr = nil
+	if err := m.Up(ctx); !errors.Is(err, ErrDirty) {
+		t.Errorf("Up on a dirty database should return ErrDirty, got %v", err)
+	}
+	if err := m.Down(ctx, 1); !errors.Is(err, ErrDirty) {
+		t.Errorf("Down on a dirty database should return ErrDirty, got %v", err)
+	}
+	status, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status) != 2 || status[0].Dirty || !status[1].Dirty {
+		t.Errorf("unexpected status: %+v, %+v", status[0], status[1])
+	}
+}
+
+func TestMigratorLockTimeout(t *testing.T) {
+	c := newFakeConnector()
+	c.lock = 0
+	m := newTestMigrator(t, c, testMigrations)
+	if err := m.Up(context.Background()); err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Fatalf("Up without the lock should fail, got %v", err)
+	}
+	if q := c.take(); len(q) != 1 {
+		t.Errorf("nothing should run without the lock, got %q", q)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	c := newFakeConnector()
+	m := newTestMigrator(t, c, testMigrations)
+	ctx := context.Background()
+	var out bytes.Buffer
+	if err := command(ctx, []string{"-dir", m.dir, "up"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if err := command(ctx, []string{"-dir", m.dir, "down", "2"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.versions) != 0 {
+		t.Errorf("down 2 should roll back all versions: %v", c.versions)
+	}
+	if err := command(ctx, []string{"-dir", m.dir, "status"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if s := out.String(); s != "1\tcreate_a\tpending\n2\talter_a\tpending\n" {
+		t.Errorf("unexpected status output: %q", s)
+	}
+	if err := command(ctx, []string{"-dir", m.dir, "down", "0"}, &out); err == nil {
+		t.Error("invalid down steps should fail")
+	}
+}
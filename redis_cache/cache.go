@@ -0,0 +1,219 @@
+// cache-aside 缓存, 基于 redis_client
+package redis_cache
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/legenove/nano-server-sdk/redis_client"
+	"github.com/legenove/nano-server-sdk/servers"
+	"github.com/legenove/utils"
+)
+
+var (
+	ErrCacheMiss = errors.New("redis_cache: cache miss")
+	// ErrNotFound loader 返回该错误时写入空值缓存, 之后的 Get 直接返回该错误
+	ErrNotFound = errors.New("redis_cache: not found")
+)
+
+// 空值缓存的占位值
+var negativeValue = []byte("\x00nano_cache_nil")
+
+type options struct {
+	codec       Codec
+	ttl         time.Duration
+	jitter      float64
+	negativeTTL time.Duration
+}
+
+type Option func(*options)
+
+// WithCodec 编解码方式, 默认 JSONCodec
+func WithCodec(c Codec) Option {
+	return func(o *options) {
+		o.codec = c
+	}
+}
+
+// WithTTL 默认过期时间, 默认 5min
+func WithTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.ttl = ttl
+	}
+}
+
+// WithJitter 过期时间随机增加 [0, ttl*jitter), 防止同时失效, 默认 0.1
+func WithJitter(jitter float64) Option {
+	return func(o *options) {
+		o.jitter = jitter
+	}
+}
+
+// WithNegativeTTL 空值缓存过期时间, 默认 30s, 0 为不缓存空值
+func WithNegativeTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.negativeTTL = ttl
+	}
+}
+
+type Cache struct {
+	router    string
+	namespace string
+	opt       options
+	group     flightGroup
+}
+
+// NewCache 创建缓存, key 统一加上 Server.Group:Server.Name:namespace: 前缀
+// 前缀在使用时读取服务信息, InitServer 之前创建的缓存也使用正确的前缀
+func NewCache(routerName, namespace string, opts ...Option) *Cache {
+	opt := options{
+		codec:       JSONCodec,
+		ttl:         5 * time.Minute,
+		jitter:      0.1,
+		negativeTTL: 30 * time.Second,
+	}
+	for _, o := range opts {
+		o(&opt)
+	}
+	return &Cache{
+		router:    routerName,
+		namespace: namespace,
+		opt:       opt,
+	}
+}
+
+func (c *Cache) prefix() string {
+	return utils.ConcatenateStrings(servers.Server.GetServerGroup(), ":",
+		servers.Server.GetServerName(), ":", c.namespace, ":")
+}
+
+// Key 返回带前缀的 redis key
+func (c *Cache) Key(key string) string {
+	return c.prefix() + key
+}
+
+func (c *Cache) client(ctx context.Context) (*redis.Client, error) {
+	if ctx == nil {
+		return redis_client.GetRedisClient(c.router)
+	}
+	return redis_client.GetRedisClientWithContext(ctx, c.router)
+}
+
+func (c *Cache) expiration(ttl time.Duration) time.Duration {
+	if c.opt.jitter > 0 {
+		ttl += time.Duration(rand.Int63n(int64(float64(ttl)*c.opt.jitter) + 1))
+	}
+	return ttl
+}
+
+// Get 读取缓存到 dest, 不存在时返回 ErrCacheMiss, 命中空值缓存时返回 ErrNotFound
+func (c *Cache) Get(ctx context.Context, key string, dest interface{}) error {
+	data, err := c.getBytes(ctx, key)
+	if err != nil {
+		return err
+	}
+	return c.opt.codec.Unmarshal(data, dest)
+}
+
+func (c *Cache) getBytes(ctx context.Context, key string) ([]byte, error) {
+	client, err := c.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := client.Get(c.Key(key)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrCacheMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+	if string(data) == string(negativeValue) {
+		return nil, ErrNotFound
+	}
+	return data, nil
+}
+
+// Set 写入缓存, 不传 ttl 时使用默认过期时间
+func (c *Cache) Set(ctx context.Context, key string, value interface{}, ttl ...time.Duration) error {
+	data, err := c.opt.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	expiration := c.opt.ttl
+	if len(ttl) > 0 {
+		expiration = ttl[0]
+	}
+	return c.setBytes(ctx, key, data, c.expiration(expiration))
+}
+
+func (c *Cache) setBytes(ctx context.Context, key string, data []byte, expiration time.Duration) error {
+	client, err := c.client(ctx)
+	if err != nil {
+		return err
+	}
+	return client.Set(c.Key(key), data, expiration).Err()
+}
+
+// Delete 删除缓存
+func (c *Cache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	client, err := c.client(ctx)
+	if err != nil {
+		return err
+	}
+	fullKeys := make([]string, len(keys))
+	for i, key := range keys {
+		fullKeys[i] = c.Key(key)
+	}
+	return client.Del(fullKeys...).Err()
+}
+
+// Fetch 读取缓存, 未命中时调用 loader 加载并写入缓存
+// 同一进程内相同 key 的并发加载只调用一次 loader
+// loader 返回 ErrNotFound 时写入空值缓存
+func (c *Cache) Fetch(ctx context.Context, key string, dest interface{}, loader func(ctx context.Context) (interface{}, error)) error {
+	data, err := c.fetchBytes(ctx, key, loader)
+	if err != nil {
+		return err
+	}
+	return c.opt.codec.Unmarshal(data, dest)
+}
+
+func (c *Cache) fetchBytes(ctx context.Context, key string, loader func(ctx context.Context) (interface{}, error)) ([]byte, error) {
+	data, err := c.getBytes(ctx, key)
+	if err != ErrCacheMiss {
+		return data, err
+	}
+	return c.loadBytes(ctx, key, loader, nil)
+}
+
+// loadBytes 调用 loader 并写入 redis, 同一个 key 并发时只加载一次
+// 写入 redis 成功后调用 stored
+func (c *Cache) loadBytes(ctx context.Context, key string, loader func(ctx context.Context) (interface{}, error), stored func()) ([]byte, error) {
+	return c.group.Do(key, func() ([]byte, error) {
+		value, err := loader(ctx)
+		if err == ErrNotFound {
+			if c.opt.negativeTTL > 0 && c.setBytes(ctx, key, negativeValue, c.opt.negativeTTL) == nil && stored != nil {
+				stored()
+			}
+			return nil, ErrNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := c.opt.codec.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		// 写缓存失败不影响返回结果
+		if c.setBytes(ctx, key, data, c.expiration(c.opt.ttl)) == nil && stored != nil {
+			stored()
+		}
+		return data, nil
+	})
+}
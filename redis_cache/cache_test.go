@@ -0,0 +1,103 @@
+package redis_cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/legenove/nano-server-sdk/servers"
+)
+
+type cacheItem struct {
+	Id   int
+	Name string
+}
+
+func TestCodec(t *testing.T) {
+	for _, codec := range []Codec{JSONCodec, GobCodec} {
+		data, err := codec.Marshal(&cacheItem{Id: 1, Name: "nano"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var item cacheItem
+		if err := codec.Unmarshal(data, &item); err != nil {
+			t.Fatal(err)
+		}
+		if item.Id != 1 || item.Name != "nano" {
+			t.Errorf("%T: unexpected item %+v", codec, item)
+		}
+	}
+	if _, err := ProtoCodec.Marshal(&cacheItem{}); err == nil {
+		t.Error("proto codec should reject non proto message")
+	}
+}
+
+func TestFlightGroup(t *testing.T) {
+	var g flightGroup
+	var calls int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := g.Do("key", func() ([]byte, error) {
+				atomic.AddInt32(&calls, 1)
+				time.Sleep(50 * time.Millisecond)
+				return []byte("v"), nil
+			})
+			if err != nil || string(v) != "v" {
+				t.Errorf("unexpected result %s %v", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("loader should be called once, got %d", calls)
+	}
+}
+
+func TestLocalLRU(t *testing.T) {
+	l := newLocalLRU(2, time.Minute)
+	l.set("a", []byte("1"))
+	l.set("b", []byte("2"))
+	l.get("a")
+	l.set("c", []byte("3"))
+	if _, ok := l.get("b"); ok {
+		t.Error("b should be evicted as least recently used")
+	}
+	if v, ok := l.get("a"); !ok || string(v) != "1" {
+		t.Error("a should be kept")
+	}
+	l.remove("a")
+	if _, ok := l.get("a"); ok {
+		t.Error("a should be removed")
+	}
+	s := l.stats()
+	if s.Evictions != 1 || s.Hits != 2 || s.Misses != 2 || s.Size != 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+
+	expired := newLocalLRU(10, time.Millisecond)
+	expired.set("a", []byte("1"))
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := expired.get("a"); ok {
+		t.Error("a should be expired")
+	}
+}
+
+// InitServer 之前创建的缓存在使用时读取服务信息
+func TestCacheKeyPrefix(t *testing.T) {
+	defer func(group, name string) {
+		servers.Server.Group, servers.Server.Name = group, name
+	}(servers.Server.Group, servers.Server.Name)
+	servers.Server.Group, servers.Server.Name = "", ""
+	c := NewCache("cache_prefix_redis", "ns")
+	if _, err := NewTwoLevelCache(c, 10, time.Minute); err == nil {
+		t.Error("two level cache should not be created before server info is set")
+	}
+	servers.Server.Group, servers.Server.Name = "group", "name"
+	if key := c.Key("k"); key != "group:name:ns:k" {
+		t.Errorf("unexpected key %q", key)
+	}
+}
@@ -0,0 +1,122 @@
+package redis_cache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type localEntry struct {
+	key      string
+	data     []byte
+	expireAt time.Time
+}
+
+// localLRU 进程内的 LRU 缓存, 超过 size 时淘汰最久未使用的 key
+type localLRU struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	ll    *list.List
+	items map[string]*list.Element
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+func newLocalLRU(size int, ttl time.Duration) *localLRU {
+	return &localLRU{
+		size:  size,
+		ttl:   ttl,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (l *localLRU) get(key string) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.items[key]; ok {
+		entry := e.Value.(*localEntry)
+		if time.Now().Before(entry.expireAt) {
+			l.ll.MoveToFront(e)
+			atomic.AddUint64(&l.hits, 1)
+			return entry.data, true
+		}
+		l.removeElement(e)
+	}
+	atomic.AddUint64(&l.misses, 1)
+	return nil, false
+}
+
+func (l *localLRU) set(key string, data []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	expireAt := time.Now().Add(l.ttl)
+	if e, ok := l.items[key]; ok {
+		l.ll.MoveToFront(e)
+		entry := e.Value.(*localEntry)
+		entry.data = data
+		entry.expireAt = expireAt
+		return
+	}
+	l.items[key] = l.ll.PushFront(&localEntry{key: key, data: data, expireAt: expireAt})
+	for l.size > 0 && l.ll.Len() > l.size {
+		l.removeElement(l.ll.Back())
+		atomic.AddUint64(&l.evictions, 1)
+	}
+}
+
+func (l *localLRU) remove(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		if e, ok := l.items[key]; ok {
+			l.removeElement(e)
+		}
+	}
+}
+
+func (l *localLRU) purge() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ll.Init()
+	l.items = make(map[string]*list.Element)
+}
+
+func (l *localLRU) removeElement(e *list.Element) {
+	l.ll.Remove(e)
+	delete(l.items, e.Value.(*localEntry).key)
+}
+
+func (l *localLRU) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ll.Len()
+}
+
+// LocalStats 本地缓存统计
+type LocalStats struct {
+	Size      int     `json:"size"`
+	Capacity  int     `json:"capacity"`
+	Hits      uint64  `json:"hits"`
+	Misses    uint64  `json:"misses"`
+	Evictions uint64  `json:"evictions"`
+	HitRatio  float64 `json:"hitRatio"`
+}
+
+func (l *localLRU) stats() *LocalStats {
+	s := &LocalStats{
+		Size:      l.len(),
+		Capacity:  l.size,
+		Hits:      atomic.LoadUint64(&l.hits),
+		Misses:    atomic.LoadUint64(&l.misses),
+		Evictions: atomic.LoadUint64(&l.evictions),
+	}
+	if total := s.Hits + s.Misses; total > 0 {
+		s.HitRatio = float64(s.Hits) / float64(total)
+	}
+	return s
+}
@@ -0,0 +1,45 @@
+package redis_cache
+
+import (
+	"errors"
+	"sync"
+)
+
+var errLoadPanic = errors.New("redis_cache: loader panic")
+
+type call struct {
+	wg  sync.WaitGroup
+	val []byte
+	err error
+}
+
+// flightGroup 相同 key 的并发加载只执行一次
+type flightGroup struct {
+	mu sync.Mutex
+	m  map[string]*call
+}
+
+func (g *flightGroup) Do(key string, fn func() ([]byte, error)) ([]byte, error) {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	if c, ok := g.m[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err
+	}
+	c := &call{err: errLoadPanic}
+	c.wg.Add(1)
+	g.m[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		c.wg.Done()
+		g.mu.Lock()
+		delete(g.m, key)
+		g.mu.Unlock()
+	}()
+	c.val, c.err = fn()
+	return c.val, c.err
+}
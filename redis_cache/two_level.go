@@ -0,0 +1,255 @@
+package redis_cache
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/legenove/nano-server-sdk/redis_client"
+	"github.com/legenove/nano-server-sdk/servers"
+	"github.com/legenove/random"
+	"github.com/legenove/utils"
+)
+
+// 消息格式: 发送实例id + "\n" + 以 "\n" 分隔的 key
+const invalidateSep = "\n"
+
+// 所有二级缓存, 按实例 id 保存, 用于 expvar 统计
+var twoLevelCaches sync.Map
+
+// ResubscribeInterval 检查路由客户端是否因配置热更新被替换的间隔
+var ResubscribeInterval = time.Second
+
+// TwoLevelCache 在 Cache 前增加进程内 LRU 缓存
+// 写入和删除会通过 redis 频道通知其他副本删除本地缓存
+// 订阅断开期间的通知会丢失, 本地缓存最多保留 localTTL
+// 路由客户端被热更新替换后在新的客户端上重新订阅, 并清空本地缓存
+type TwoLevelCache struct {
+	*Cache
+	local   *localLRU
+	channel string
+	id      string
+
+	mu        sync.Mutex
+	subClient *redis.Client // 当前订阅所在的客户端
+	pubsub    *redis.PubSub
+	closed    bool
+}
+
+// NewTwoLevelCache size 为本地缓存的最大 key 数量, localTTL 为本地缓存过期时间
+// 通知频道包含服务信息, 需要在 InitServer 之后创建
+func NewTwoLevelCache(cache *Cache, size int, localTTL time.Duration) (*TwoLevelCache, error) {
+	if servers.Server.GetServerName() == "" {
+		return nil, errors.New("redis_cache: server info not initialized, create two level cache after InitServer")
+	}
+	client, err := redis_client.GetRedisClient(cache.router)
+	if err != nil {
+		return nil, err
+	}
+	c := &TwoLevelCache{
+		Cache:   cache,
+		local:   newLocalLRU(size, localTTL),
+		channel: cache.prefix() + "__invalidate",
+		id:      random.UuidV4(),
+	}
+	pubsub, err := c.subscribe(client)
+	if err != nil {
+		return nil, err
+	}
+	c.subClient, c.pubsub = client, pubsub
+	go c.listen(pubsub.Channel())
+	twoLevelCaches.Store(c.id, c)
+	return c, nil
+}
+
+func (c *TwoLevelCache) subscribe(client *redis.Client) (*redis.PubSub, error) {
+	pubsub := client.Subscribe(c.channel)
+	if _, err := pubsub.Receive(); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+	return pubsub, nil
+}
+
+func (c *TwoLevelCache) listen(ch <-chan *redis.Message) {
+	ticker := time.NewTicker(ResubscribeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				// 订阅被关闭, Close 时退出, 否则等待重新订阅
+				ch = nil
+				if c.isClosed() {
+					return
+				}
+				continue
+			}
+			parts := strings.Split(msg.Payload, invalidateSep)
+			if len(parts) < 2 || parts[0] == c.id {
+				continue
+			}
+			c.local.remove(parts[1:]...)
+		case <-ticker.C:
+			if c.isClosed() {
+				return
+			}
+			if next := c.resubscribe(ch == nil); next != nil {
+				ch = next
+			}
+		}
+	}
+}
+
+func (c *TwoLevelCache) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// resubscribe 路由客户端变化或订阅已断开时重新订阅, 返回新的消息通道
+func (c *TwoLevelCache) resubscribe(force bool) <-chan *redis.Message {
+	client, err := redis_client.GetRedisClient(c.router)
+	if err != nil {
+		return nil
+	}
+	c.mu.Lock()
+	same := client == c.subClient
+	c.mu.Unlock()
+	if same && !force {
+		return nil
+	}
+	pubsub, err := c.subscribe(client)
+	if err != nil {
+		return nil
+	}
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		pubsub.Close()
+		return nil
+	}
+	old := c.pubsub
+	c.subClient, c.pubsub = client, pubsub
+	c.mu.Unlock()
+	old.Close()
+	// 切换期间的通知可能丢失
+	c.local.purge()
+	return pubsub.Channel()
+}
+
+func (c *TwoLevelCache) publish(ctx context.Context, keys ...string) error {
+	client, err := c.client(ctx)
+	if err != nil {
+		return err
+	}
+	payload := c.id + invalidateSep + strings.Join(keys, invalidateSep)
+	return client.Publish(c.channel, payload).Err()
+}
+
+// Get 优先读取本地缓存
+func (c *TwoLevelCache) Get(ctx context.Context, key string, dest interface{}) error {
+	data, err := c.getBytes(ctx, key)
+	if err != nil {
+		return err
+	}
+	return c.opt.codec.Unmarshal(data, dest)
+}
+
+func (c *TwoLevelCache) getBytes(ctx context.Context, key string) ([]byte, error) {
+	if data, ok := c.local.get(key); ok {
+		if string(data) == string(negativeValue) {
+			return nil, ErrNotFound
+		}
+		return data, nil
+	}
+	data, err := c.Cache.getBytes(ctx, key)
+	if err == nil {
+		c.local.set(key, data)
+	} else if err == ErrNotFound {
+		c.local.set(key, negativeValue)
+	}
+	return data, err
+}
+
+// Set 写入 redis 与本地缓存, 并通知其他副本
+func (c *TwoLevelCache) Set(ctx context.Context, key string, value interface{}, ttl ...time.Duration) error {
+	data, err := c.opt.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	expiration := c.opt.ttl
+	if len(ttl) > 0 {
+		expiration = ttl[0]
+	}
+	if err := c.setBytes(ctx, key, data, c.expiration(expiration)); err != nil {
+		c.local.remove(key)
+		return err
+	}
+	c.local.set(key, data)
+	return c.publish(ctx, key)
+}
+
+// Delete 删除 redis 与本地缓存, 并通知其他副本
+func (c *TwoLevelCache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	c.local.remove(keys...)
+	if err := c.Cache.Delete(ctx, keys...); err != nil {
+		return err
+	}
+	return c.publish(ctx, keys...)
+}
+
+// Fetch 依次读取本地缓存, redis, loader
+// loader 的结果写入 redis 后通知其他副本, 避免其他副本继续使用本地的旧值
+func (c *TwoLevelCache) Fetch(ctx context.Context, key string, dest interface{}, loader func(ctx context.Context) (interface{}, error)) error {
+	data, err := c.getBytes(ctx, key)
+	if err == ErrCacheMiss {
+		data, err = c.loadBytes(ctx, key, loader, func() {
+			_ = c.publish(ctx, key)
+		})
+		if err == nil {
+			c.local.set(key, data)
+		} else if err == ErrNotFound {
+			c.local.set(key, negativeValue)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return c.opt.codec.Unmarshal(data, dest)
+}
+
+// Stats 本地缓存统计
+func (c *TwoLevelCache) Stats() *LocalStats {
+	return c.local.stats()
+}
+
+// Close 取消订阅并清空本地缓存
+func (c *TwoLevelCache) Close() error {
+	twoLevelCaches.Delete(c.id)
+	c.local.purge()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return c.pubsub.Close()
+}
+
+func init() {
+	expvar.Publish("redis_cache", expvar.Func(func() interface{} {
+		// 同一 namespace 可以有多个实例, 名称为 前缀#实例 id
+		res := make(map[string]*LocalStats)
+		twoLevelCaches.Range(func(k, v interface{}) bool {
+			c := v.(*TwoLevelCache)
+			res[utils.ConcatenateStrings(c.prefix(), "#", c.id)] = c.Stats()
+			return true
+		})
+		return res
+	}))
+}
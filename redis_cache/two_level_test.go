@@ -0,0 +1,151 @@
+package redis_cache
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/legenove/nano-server-sdk/redis_client"
+	"github.com/legenove/nano-server-sdk/redis_client/redistest"
+	"github.com/legenove/nano-server-sdk/servers"
+)
+
+func init() {
+	// 二级缓存的通知频道需要服务信息
+	servers.Server.Group, servers.Server.Name = "test", "redis_cache"
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func cachedLocal(c *TwoLevelCache, key string) string {
+	data, _ := c.local.get(key)
+	return string(data)
+}
+
+// 路由被替换后在新的客户端上重新订阅, 其他副本的写入仍能删除本地缓存
+func TestTwoLevelCacheAfterReload(t *testing.T) {
+	defer func(d time.Duration) { ResubscribeInterval = d }(ResubscribeInterval)
+	ResubscribeInterval = 10 * time.Millisecond
+	mr := redistest.Start(t, "cache_reload_redis")
+	ctx := context.Background()
+	a, err := NewTwoLevelCache(NewCache("cache_reload_redis", "reload"), 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := NewTwoLevelCache(NewCache("cache_reload_redis", "reload"), 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	err = redis_client.RegisterRedis("cache_reload_redis", redis_client.RedisSetting{
+		Type: redis_client.RedisTypeMaster,
+		Url:  mr.Addr(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newClient, _ := redis_client.GetRedisClient("cache_reload_redis")
+	waitFor(t, func() bool {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		return a.subClient == newClient
+	})
+
+	var v string
+	if err := b.Set(ctx, "k", "v1"); err != nil {
+		t.Fatalf("Set after reload: %v", err)
+	}
+	if err := a.Get(ctx, "k", &v); err != nil || v != "v1" {
+		t.Fatalf("Get after reload: %v %v", v, err)
+	}
+	if err := b.Set(ctx, "k", "v2"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return cachedLocal(a, "k") == "" })
+	if err := a.Get(ctx, "k", &v); err != nil || v != "v2" {
+		t.Fatalf("expected v2 after invalidation, got %v %v", v, err)
+	}
+}
+
+// loader 的结果写入 redis 后通知其他副本删除本地的旧值, 且不重复读取 redis
+func TestTwoLevelCacheFetchPublishes(t *testing.T) {
+	mr := redistest.Start(t, "cache_fetch_redis")
+	ctx := context.Background()
+	a, err := NewTwoLevelCache(NewCache("cache_fetch_redis", "fetch"), 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := NewTwoLevelCache(NewCache("cache_fetch_redis", "fetch"), 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	var v string
+	if err := a.Set(ctx, "k", "old"); err != nil {
+		t.Fatal(err)
+	}
+	// 模拟 redis 中的值过期, a 的本地缓存仍是旧值
+	mr.Del(a.Key("k"))
+	before := mr.CommandCount()
+	err = b.Fetch(ctx, "k", &v, func(ctx context.Context) (interface{}, error) {
+		return "new", nil
+	})
+	if err != nil || v != "new" {
+		t.Fatalf("Fetch: %v %v", v, err)
+	}
+	// GET, SET, PUBLISH
+	if n := mr.CommandCount() - before; n != 3 {
+		t.Errorf("Fetch should send 3 commands, sent %d", n)
+	}
+	waitFor(t, func() bool { return cachedLocal(a, "k") == "" })
+	if err := a.Get(ctx, "k", &v); err != nil || v != "new" {
+		t.Fatalf("expected new after invalidation, got %v %v", v, err)
+	}
+}
+
+// 同一 namespace 的多个实例分别统计
+func TestTwoLevelCacheStats(t *testing.T) {
+	redistest.Start(t, "cache_stats_redis")
+	a, err := NewTwoLevelCache(NewCache("cache_stats_redis", "stats"), 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewTwoLevelCache(NewCache("cache_stats_redis", "stats"), 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	var stats map[string]*LocalStats
+	if err := json.Unmarshal([]byte(expvar.Get("redis_cache").String()), &stats); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []*TwoLevelCache{a, b} {
+		if _, ok := stats[c.prefix()+"#"+c.id]; !ok {
+			t.Errorf("stats of %s missing: %v", c.id, stats)
+		}
+	}
+	a.Close()
+	stats = nil
+	json.Unmarshal([]byte(expvar.Get("redis_cache").String()), &stats)
+	if _, ok := stats[a.prefix()+"#"+a.id]; ok {
+		t.Error("closed cache should be removed from stats")
+	}
+	if _, ok := stats[b.prefix()+"#"+b.id]; !ok {
+		t.Error("closing a should not remove b")
+	}
+}
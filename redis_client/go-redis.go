@@ -12,14 +12,30 @@ import (
 type mangers struct {
 	clients  map[string]*redis.Client
 	clusters map[string]*redis.ClusterClient
+	watchers map[string]*sentinelWatcher
+	routers  map[string]*redisRouter
+	hooks    map[*redis.Client]*logHook
 	sync.Mutex
 }
 
 var redisConf *viper_conf.ViperConf
-var Manager = &mangers{clients: make(map[string]*redis.Client), clusters: make(map[string]*redis.ClusterClient)}
+var Manager = newManager()
 var redisSettings = make(map[string]*RedisSetting)
 
-func loadRedisManager(rsettings []*RedisSetting, b ...bool) {
+// 加载时的原始配置，getter 会修正 redisSettings 中的值，热更新时使用原始配置对比
+var redisRawSettings = make(map[string]RedisSetting)
+
+func newManager() *mangers {
+	return &mangers{
+		clients:  make(map[string]*redis.Client),
+		clusters: make(map[string]*redis.ClusterClient),
+		watchers: make(map[string]*sentinelWatcher),
+		routers:  make(map[string]*redisRouter),
+		hooks:    make(map[*redis.Client]*logHook),
+	}
+}
+
+func loadRedisManager(rsettings []*RedisSetting, b ...bool) error {
 	preload := true
 	if len(b) > 0 {
 		preload = b[0]
@@ -34,6 +50,20 @@ func loadRedisManager(rsettings []*RedisSetting, b ...bool) {
 				// TODO
 			}
 
+		} else if rsetting.Type == RedisTypeSentinel {
+			if _, ok := Manager.clients[rsetting.RouterName]; preload && !ok {
+				Manager.clients[rsetting.RouterName] = newRedisFailoverClient(rsetting)
+				Manager.watchers[rsetting.RouterName] = watchSentinel(rsetting)
+			}
+		} else if rsetting.Type == RedisTypeGroup {
+			if _, ok := Manager.clients[rsetting.RouterName]; preload && !ok {
+				client, router, err := newRedisGroupClient(rsetting)
+				if err != nil {
+					return err
+				}
+				Manager.clients[rsetting.RouterName] = client
+				Manager.routers[rsetting.RouterName] = router
+			}
 		} else {
 			panic("redis conf type not support :" + rsetting.Type)
 		}
@@ -41,13 +71,7 @@ func loadRedisManager(rsettings []*RedisSetting, b ...bool) {
 			redisSettings[rsetting.RouterName] = rsetting
 		}
 	}
-}
-
-func removeRedis() {
-	Manager.Lock()
-	defer Manager.Unlock()
-	Manager = &mangers{clients: make(map[string]*redis.Client), clusters: make(map[string]*redis.ClusterClient)}
-	redisSettings = make(map[string]*RedisSetting)
+	return nil
 }
 
 func getRedisConf(key string) (*RedisSetting, error) {
@@ -69,6 +93,16 @@ func getRedisConf(key string) (*RedisSetting, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Invalid redis conf:%s; err:%s", key, err.Error())
 	}
+	if setting.RouterName == "" {
+		setting.RouterName = key
+	}
+	raw := setting
+	if err := setting.Validate(); err != nil {
+		return nil, err
+	}
+	if _, ok := redisRawSettings[setting.RouterName]; !ok {
+		redisRawSettings[setting.RouterName] = raw
+	}
 	return &setting, nil
 }
 
@@ -76,17 +110,19 @@ func newRedisConfig() error {
 	var err error
 	redisFileName := cocore.App.GetStringConfig("redis_conf", "redis.toml")
 	redisConf, err = cocore.Conf.Instance(redisFileName, nil)
+	initRedisLog()
+	cocore.RegisterInitFunc("redisLog", initRedisLog)
 	go listenOnRedisChange(redisConf)
 	return err
 }
 
 func listenOnRedisChange(v *viper_conf.ViperConf) {
 	if v != nil {
-		<- v.OnChange
+		<-v.OnChange
 		for {
 			select {
-			case <- v.OnChange:
-				removeRedis()
+			case <-v.OnChange:
+				reloadRedis()
 			}
 		}
 	}
@@ -110,7 +146,10 @@ func (m *mangers) GetRedisClient(key string) (*redis.Client, error) {
 	m.Lock()
 	client, ok := m.clients[key]
 	if !ok {
-		loadRedisManager([]*RedisSetting{redisSetting})
+		if err := loadRedisManager([]*RedisSetting{redisSetting}); err != nil {
+			m.Unlock()
+			return nil, err
+		}
 		client, ok = m.clients[key]
 		if !ok {
 			m.Unlock()
@@ -137,7 +176,9 @@ func (m *mangers) GetRedisClusterClient(key string) (*redis.ClusterClient, error
 	defer m.Unlock()
 	client, ok := m.clusters[key]
 	if !ok {
-		loadRedisManager([]*RedisSetting{redisSetting})
+		if err := loadRedisManager([]*RedisSetting{redisSetting}); err != nil {
+			return nil, err
+		}
 		client, ok = m.clusters[key]
 		if !ok {
 			return nil, errors.New(fmt.Sprintf("%s : redis client can't be created, url: %s",
@@ -150,7 +191,8 @@ func (m *mangers) GetRedisClusterClient(key string) (*redis.ClusterClient, error
 func newRedisClient(setting *RedisSetting) *redis.Client {
 	opt := &redis.Options{
 		Addr:               setting.GetAddr(),
-		Password:           setting.GetPassword(),
+		Password:           setting.getOptPassword(),
+		DB:                 setting.getOptDB(),
 		PoolSize:           setting.GetPoolSize(),
 		MinIdleConns:       setting.GetMinIdleConns(),
 		DialTimeout:        setting.GetDialTimeout(),
@@ -158,10 +200,10 @@ func newRedisClient(setting *RedisSetting) *redis.Client {
 		WriteTimeout:       setting.GetWriteTimeout(),
 		IdleTimeout:        setting.GetIdleTimeout(),
 		IdleCheckFrequency: setting.GetIdleCheckFrequency(),
-		OnConnect: func(conn *redis.Conn) error {
-			_, err := conn.Ping().Result()
-			return err
-		},
+		TLSConfig:          setting.GetTLSConfig(),
+		OnConnect:          setting.onConnect,
 	}
-	return redis.NewClient(opt)
+	client := redis.NewClient(opt)
+	installLogHook(setting.RouterName, client)
+	return client
 }
@@ -1,20 +1,194 @@
-package redis_client
+package redis_client_test
 
 import (
-	"fmt"
-	"github.com/legenove/cocore"
+	"os"
 	"testing"
+	"time"
+
+	"github.com/legenove/cocore"
+	"github.com/legenove/nano-server-sdk/redis_client"
+	"github.com/legenove/nano-server-sdk/redis_client/redistest"
 )
 
 func init() {
-	cocore.InitApp(true, "", "$GOPATH/src/github.com/legenove/redis_client/conf", "")
+	// 没有 redis.toml，未注册的路由会返回错误
+	cocore.InitApp(true, "", os.TempDir(), "")
 }
 
 func TestGetRedisClient(t *testing.T) {
-	client, err := GetRedisClient("default_redis")
+	mr := redistest.Start(t, "default_redis")
+	client, err := redis_client.GetRedisClient("default_redis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Ping().Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Set("key", "value", time.Second).Err(); err != nil {
+		t.Fatal(err)
+	}
+	mr.FastForward(2 * time.Second)
+	if mr.Exists("key") {
+		t.Fatal("key should be expired")
+	}
+}
+
+func TestRegisterRedisReplace(t *testing.T) {
+	first := redistest.Start(t, "replace_redis")
+	client, err := redis_client.GetRedisClient("replace_redis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Set("key", "first", 0)
+
+	second := redistest.Start(t, "replace_redis")
+	client, err = redis_client.GetRedisClient("replace_redis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Set("key", "second", 0)
+	if v, _ := first.Get("key"); v != "first" {
+		t.Fatalf("first server got %q", v)
+	}
+	if v, _ := second.Get("key"); v != "second" {
+		t.Fatalf("second server got %q", v)
+	}
+}
+
+func TestUnregisterRedis(t *testing.T) {
+	s, err := redistest.NewServer("unregister_redis")
 	if err != nil {
-		fmt.Println(err)
-		return
+		t.Fatal(err)
+	}
+	s.Close()
+	if _, err := redis_client.GetRedisClient("unregister_redis"); err == nil {
+		t.Fatal("router should be removed")
+	}
+}
+
+// group 的 pipeline 发往主库, 与普通命令共用主库的链接池
+func TestGroupPipelineUsesMaster(t *testing.T) {
+	master := redistest.Start(t, "group_master_redis")
+	replica := redistest.Start(t, "group_slaver_redis")
+	err := redis_client.RegisterRedis("group_slaver_redis", redis_client.RedisSetting{
+		Type: redis_client.RedisTypeSlaver,
+		Url:  replica.Addr(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = redis_client.RegisterRedis("group_redis", redis_client.RedisSetting{
+		Type:    redis_client.RedisTypeGroup,
+		Master:  "group_master_redis",
+		Slavers: []string{"group_slaver_redis"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer redis_client.UnregisterRedis("group_redis")
+	client, err := redis_client.GetRedisClient("group_redis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pipe := client.Pipeline()
+	pipe.Set("a", "1", 0)
+	pipe.Set("b", "2", 0)
+	if _, err := pipe.Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Set("c", "3", 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if v, _ := master.Get(key); v == "" {
+			t.Errorf("%s should be written to master", key)
+		}
+	}
+	if n := master.TotalConnectionCount(); n != 1 {
+		t.Errorf("pipeline should reuse the master pool, master got %d connections", n)
+	}
+}
+
+// group 的主库被重新注册后, group 使用新的主库
+func TestGroupFollowsMasterReplace(t *testing.T) {
+	redistest.Start(t, "replace_group_master_redis")
+	replica := redistest.Start(t, "replace_group_slaver_redis")
+	err := redis_client.RegisterRedis("replace_group_slaver_redis", redis_client.RedisSetting{
+		Type: redis_client.RedisTypeSlaver,
+		Url:  replica.Addr(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = redis_client.RegisterRedis("replace_group_redis", redis_client.RedisSetting{
+		Type:    redis_client.RedisTypeGroup,
+		Master:  "replace_group_master_redis",
+		Slavers: []string{"replace_group_slaver_redis"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer redis_client.UnregisterRedis("replace_group_redis")
+	client, err := redis_client.GetRedisClient("replace_group_redis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Set("key", "first", 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	second := redistest.Start(t, "replace_group_master_redis")
+	client, err = redis_client.GetRedisClient("replace_group_redis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Set("key", "second", 0).Err(); err != nil {
+		t.Fatalf("group should not use the closed master client: %v", err)
+	}
+	if v, _ := second.Get("key"); v != "second" {
+		t.Errorf("group should write to the new master, got %q", v)
+	}
+
+	redis_client.UnregisterRedis("replace_group_master_redis")
+	if _, err := redis_client.GetRedisClient("replace_group_redis"); err == nil {
+		t.Error("group should fail after its master is removed")
+	}
+}
+
+// group 共用主库的链接池, 链接池状态只统计在主库下
+func TestGroupPoolStats(t *testing.T) {
+	redistest.Start(t, "stats_master_redis")
+	replica := redistest.Start(t, "stats_slaver_redis")
+	err := redis_client.RegisterRedis("stats_slaver_redis", redis_client.RedisSetting{
+		Type: redis_client.RedisTypeSlaver,
+		Url:  replica.Addr(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = redis_client.RegisterRedis("stats_group_redis", redis_client.RedisSetting{
+		Type:    redis_client.RedisTypeGroup,
+		Master:  "stats_master_redis",
+		Slavers: []string{"stats_slaver_redis"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer redis_client.UnregisterRedis("stats_group_redis")
+	client, err := redis_client.GetRedisClient("stats_group_redis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Set("key", "value", 0)
+	stats := redis_client.PoolStats()
+	group, master := stats["stats_group_redis"], stats["stats_master_redis"]
+	if group == nil || master == nil {
+		t.Fatalf("missing stats: %v", stats)
+	}
+	if group.PoolOf != "stats_master_redis" || group.TotalConns != 0 {
+		t.Errorf("group should report the master pool as an alias, got %+v", group)
+	}
+	if master.TotalConns != 1 {
+		t.Errorf("master pool should hold the group connection, got %+v", master)
 	}
-	fmt.Println(client.Ping().Result())
 }
@@ -0,0 +1,146 @@
+package redis_client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/legenove/cocore"
+	"github.com/legenove/nano-server-sdk/servers"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/metadata"
+)
+
+// SlowLogThreshold 慢命令阈值，超过阈值的命令始终写入 redis 日志
+// 通过 app 配置 REDIS_SLOW_LOG_MS 设置，默认 100 毫秒
+var SlowLogThreshold = 100 * time.Millisecond
+
+func initRedisLog() {
+	ms, err := strconv.Atoi(cocore.App.GetStringConfig("REDIS_SLOW_LOG_MS", "100"))
+	if err != nil || ms <= 0 {
+		ms = 100
+	}
+	SlowLogThreshold = time.Duration(ms) * time.Millisecond
+}
+
+// WithContext 返回绑定请求上下文的客户端，命令日志中会带上请求 id
+// client 需为 GetRedisClient 返回的客户端
+func WithContext(ctx context.Context, client *redis.Client) *redis.Client {
+	Manager.Lock()
+	h := Manager.hooks[client]
+	Manager.Unlock()
+	c := client.WithContext(ctx)
+	if h != nil {
+		h.install(c, ctx)
+	}
+	return c
+}
+
+// GetRedisClientWithContext 获取绑定请求上下文的客户端
+func GetRedisClientWithContext(ctx context.Context, key string) (*redis.Client, error) {
+	client, err := GetRedisClient(key)
+	if err != nil {
+		return nil, err
+	}
+	return WithContext(ctx, client), nil
+}
+
+// logHook 保存安装日志前的 process, WithContext 复制出的客户端在其上重新安装带请求上下文的日志
+type logHook struct {
+	name            string
+	process         func(cmd redis.Cmder) error
+	processPipeline func(cmds []redis.Cmder) error
+}
+
+// installLogHook 为客户端安装命令日志与耗时统计, 调用方需持有 Manager 的锁
+// DBDebugLog 开启时记录所有命令，否则只记录慢命令
+func installLogHook(name string, client *redis.Client) *logHook {
+	h := &logHook{name: name}
+	client.WrapProcess(func(old func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
+		h.process = old
+		return old
+	})
+	client.WrapProcessPipeline(func(old func(cmds []redis.Cmder) error) func(cmds []redis.Cmder) error {
+		h.processPipeline = old
+		return old
+	})
+	h.install(client, nil)
+	Manager.hooks[client] = h
+	return h
+}
+
+// install 替换 client 的 process, 日志使用 ctx 中的请求 id, ctx 为 nil 时不记录请求 id
+func (h *logHook) install(client *redis.Client, ctx context.Context) {
+	client.WrapProcess(func(func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
+		return func(cmd redis.Cmder) error {
+			start := time.Now()
+			err := h.process(cmd)
+			duration := time.Since(start)
+			observeLatency(h.name, duration)
+			logCommand(ctx, h.name, cmd, duration, false)
+			return err
+		}
+	})
+	client.WrapProcessPipeline(func(func(cmds []redis.Cmder) error) func(cmds []redis.Cmder) error {
+		return func(cmds []redis.Cmder) error {
+			start := time.Now()
+			err := h.processPipeline(cmds)
+			duration := time.Since(start)
+			observeLatency(h.name, duration)
+			for _, cmd := range cmds {
+				logCommand(ctx, h.name, cmd, duration, true)
+			}
+			return err
+		}
+	})
+}
+
+func logCommand(ctx context.Context, name string, cmd redis.Cmder, duration time.Duration, pipeline bool) {
+	slow := duration >= SlowLogThreshold
+	if !slow && !servers.Server.DBDebugLog {
+		return
+	}
+	logger, err := redisLogger()
+	if err != nil {
+		return
+	}
+	// 没有请求上下文的命令不生成请求 id
+	var requestId string
+	if ctx != nil {
+		if _, ok := metadata.FromIncomingContext(ctx); ok {
+			requestId = servers.GetRequestId(ctx)
+		}
+	}
+	fields := []zap.Field{
+		zap.String("requestId", requestId),
+		zap.Namespace("properties"),
+		zap.String("router", name),
+		zap.String("command", cmd.Name()),
+		zap.String("key", commandKey(cmd)),
+		zap.Bool("pipeline", pipeline),
+		zap.Bool("slow", slow),
+		zap.Duration("time", duration),
+	}
+	if err := cmd.Err(); err != nil && err != redis.Nil {
+		fields = append(fields, zap.String("error", err.Error()))
+	}
+	if slow {
+		logger.Warn("redis", fields...)
+	} else {
+		logger.Info("redis", fields...)
+	}
+}
+
+// commandKey 命令的第一个参数，一般为 key
+func commandKey(cmd redis.Cmder) string {
+	args := cmd.Args()
+	if len(args) < 2 {
+		return ""
+	}
+	if s, ok := args[1].(string); ok {
+		return s
+	}
+	return fmt.Sprint(args[1])
+}
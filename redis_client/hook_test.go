@@ -0,0 +1,93 @@
+package redis_client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/legenove/nano-server-sdk/servers"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc/metadata"
+)
+
+func observeRedisLog(t *testing.T) *observer.ObservedLogs {
+	core, logs := observer.New(zap.InfoLevel)
+	old := redisLogger
+	redisLogger = func() (*zap.Logger, error) { return zap.New(core), nil }
+	debug := servers.Server.DBDebugLog
+	servers.Server.DBDebugLog = true
+	t.Cleanup(func() {
+		redisLogger = old
+		servers.Server.DBDebugLog = debug
+	})
+	return logs
+}
+
+func registerMiniredis(t *testing.T, name, typ string) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(mr.Close)
+	if err := RegisterRedis(name, RedisSetting{Type: typ, Url: mr.Addr()}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { UnregisterRedis(name) })
+}
+
+// group 的命令日志记录在 group 下, 并带上请求 id
+func TestHookGroupLog(t *testing.T) {
+	registerMiniredis(t, "hook_master_redis", RedisTypeMaster)
+	registerMiniredis(t, "hook_slaver_redis", RedisTypeSlaver)
+	err := RegisterRedis("hook_group_redis", RedisSetting{
+		Type:    RedisTypeGroup,
+		Master:  "hook_master_redis",
+		Slavers: []string{"hook_slaver_redis"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer UnregisterRedis("hook_group_redis")
+	logs := observeRedisLog(t)
+
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(servers.SERVER_INCOME_REQUEST_ID, "hook-request"))
+	client, err := GetRedisClientWithContext(ctx, "hook_group_redis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Set("a", "1", 0)
+	client.Get("a")
+	pipe := client.Pipeline()
+	pipe.Get("a")
+	pipe.Exec()
+
+	// 从库健康检查的 ping 记录在从库下
+	var commands int
+	for _, e := range logs.TakeAll() {
+		props, _ := e.ContextMap()["properties"].(map[string]interface{})
+		if props["command"] == "ping" {
+			continue
+		}
+		commands++
+		if props["router"] != "hook_group_redis" {
+			t.Errorf("%v should be logged under the group, got %v", props["command"], props["router"])
+		}
+		if id := e.ContextMap()["requestId"]; id != "hook-request" {
+			t.Errorf("%v should carry the request id, got %v", props["command"], id)
+		}
+	}
+	if commands != 3 {
+		t.Errorf("each command should be logged once, got %d entries", commands)
+	}
+
+	// 未绑定请求上下文的客户端不记录请求 id
+	plain, _ := GetRedisClient("hook_group_redis")
+	plain.Get("a")
+	for _, e := range logs.TakeAll() {
+		if id := e.ContextMap()["requestId"]; id != "" {
+			t.Errorf("client without context should not log a request id, got %v", id)
+		}
+	}
+}
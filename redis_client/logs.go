@@ -0,0 +1,20 @@
+package redis_client
+
+import (
+	"github.com/legenove/nano-server-sdk/servers"
+	"go.uber.org/zap"
+)
+
+// redisLogger 返回写入 redis 日志流的 logger, 测试时替换
+var redisLogger = func() (*zap.Logger, error) {
+	logger, err := servers.LogInstance(servers.LogDirRedis)
+	if err != nil {
+		return nil, err
+	}
+	return logger.With(
+		zap.String("log_type", servers.LOG_TYPE_REDIS),
+		zap.String("event", servers.LogEventRedis),
+		zap.String("logServer", servers.Server.GetServerName()),
+		zap.String("logServerGroup", servers.Server.GetServerGroup()),
+	), nil
+}
@@ -0,0 +1,120 @@
+package redis_client
+
+import (
+	"expvar"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// 命令耗时分布的桶上限, 毫秒
+var latencyBuckets = []int64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000}
+
+type latencyHistogram struct {
+	counts [11]uint64 // 最后一个桶为 +Inf
+	count  uint64
+	sum    int64 // 纳秒
+}
+
+func (h *latencyHistogram) observe(d time.Duration) {
+	ms := int64(d / time.Millisecond)
+	i := 0
+	for ; i < len(latencyBuckets); i++ {
+		if ms < latencyBuckets[i] {
+			break
+		}
+	}
+	atomic.AddUint64(&h.counts[i], 1)
+	atomic.AddUint64(&h.count, 1)
+	atomic.AddInt64(&h.sum, int64(d))
+}
+
+func (h *latencyHistogram) snapshot() map[string]interface{} {
+	buckets := make(map[string]uint64, len(h.counts))
+	for i := range h.counts {
+		le := "+Inf"
+		if i < len(latencyBuckets) {
+			le = strconv.FormatInt(latencyBuckets[i], 10) + "ms"
+		}
+		buckets[le] = atomic.LoadUint64(&h.counts[i])
+	}
+	return map[string]interface{}{
+		"count":   atomic.LoadUint64(&h.count),
+		"sum":     time.Duration(atomic.LoadInt64(&h.sum)).String(),
+		"buckets": buckets,
+	}
+}
+
+// 每个路由的命令耗时分布
+var latencies sync.Map
+
+func observeLatency(name string, d time.Duration) {
+	h, ok := latencies.Load(name)
+	if !ok {
+		h, _ = latencies.LoadOrStore(name, &latencyHistogram{})
+	}
+	h.(*latencyHistogram).observe(d)
+}
+
+// RouterStats 路由的链接池状态
+type RouterStats struct {
+	Hits       uint32                 `json:"hits"`             // 从池中获取到空闲链接的次数
+	Misses     uint32                 `json:"misses"`           // 池中没有空闲链接的次数
+	Timeouts   uint32                 `json:"timeouts"`         // 等待链接超时的次数
+	TotalConns uint32                 `json:"totalConns"`       // 总链接数
+	IdleConns  uint32                 `json:"idleConns"`        // 空闲链接数
+	StaleConns uint32                 `json:"staleConns"`       // 被回收的过期链接数
+	PoolOf     string                 `json:"poolOf,omitempty"` // group 共用该主库路由的链接池, 链接池状态见主库
+	Latency    map[string]interface{} `json:"latency,omitempty"`
+}
+
+func newRouterStats(name string, stats *redis.PoolStats) *RouterStats {
+	s := &RouterStats{
+		Hits:       stats.Hits,
+		Misses:     stats.Misses,
+		Timeouts:   stats.Timeouts,
+		TotalConns: stats.TotalConns,
+		IdleConns:  stats.IdleConns,
+		StaleConns: stats.StaleConns,
+	}
+	if h, ok := latencies.Load(name); ok {
+		s.Latency = h.(*latencyHistogram).snapshot()
+	}
+	return s
+}
+
+// PoolStats 返回 Manager 中所有客户端的链接池状态, 供监控导出使用
+func PoolStats() map[string]*RouterStats {
+	return Manager.PoolStats()
+}
+
+func (m *mangers) PoolStats() map[string]*RouterStats {
+	m.Lock()
+	defer m.Unlock()
+	res := make(map[string]*RouterStats, len(m.clients)+len(m.clusters))
+	for name, client := range m.clients {
+		// group 的客户端复制自主库客户端, 链接池与主库相同, 只记录命令耗时
+		if _, ok := m.routers[name]; ok {
+			stats := newRouterStats(name, &redis.PoolStats{})
+			if setting, ok := redisSettings[name]; ok {
+				stats.PoolOf = setting.GetMaster()
+			}
+			res[name] = stats
+			continue
+		}
+		res[name] = newRouterStats(name, client.PoolStats())
+	}
+	for name, client := range m.clusters {
+		res[name] = newRouterStats(name, client.PoolStats())
+	}
+	return res
+}
+
+func init() {
+	expvar.Publish("redis", expvar.Func(func() interface{} {
+		return PoolStats()
+	}))
+}
@@ -0,0 +1,21 @@
+package redis_client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	h := &latencyHistogram{}
+	h.observe(500 * time.Microsecond)
+	h.observe(3 * time.Millisecond)
+	h.observe(2 * time.Second)
+	snap := h.snapshot()
+	if snap["count"].(uint64) != 3 {
+		t.Errorf("count should be 3, got %v", snap["count"])
+	}
+	buckets := snap["buckets"].(map[string]uint64)
+	if buckets["1ms"] != 1 || buckets["5ms"] != 1 || buckets["+Inf"] != 1 {
+		t.Errorf("unexpected buckets: %v", buckets)
+	}
+}
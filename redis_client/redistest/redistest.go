@@ -0,0 +1,60 @@
+// 测试使用的内存 redis, 启动后注册到 redis_client, 不需要配置文件与外部 redis
+//
+//	func TestXxx(t *testing.T) {
+//		mr := redistest.Start(t, "default_redis")
+//		client, _ := redis_client.GetRedisClient("default_redis")
+//		...
+//		mr.FastForward(time.Minute) // 模拟 key 过期
+//	}
+package redistest
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/legenove/nano-server-sdk/redis_client"
+)
+
+// Server 内存 redis, 支持 RESP 协议与 lua 脚本
+type Server struct {
+	*miniredis.Miniredis
+	router string
+}
+
+// NewServer 启动内存 redis 并注册为 routerName, 使用完后需要调用 Close
+func NewServer(routerName string) (*Server, error) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		return nil, err
+	}
+	err = redis_client.RegisterRedis(routerName, redis_client.RedisSetting{
+		Type: redis_client.RedisTypeMaster,
+		Url:  mr.Addr(),
+	})
+	if err != nil {
+		mr.Close()
+		return nil, err
+	}
+	return &Server{Miniredis: mr, router: routerName}, nil
+}
+
+func (s *Server) RouterName() string {
+	return s.router
+}
+
+// Close 移除路由并关闭内存 redis
+func (s *Server) Close() {
+	redis_client.UnregisterRedis(s.router)
+	s.Miniredis.Close()
+}
+
+// Start 启动内存 redis 并注册为 routerName, 测试结束时自动关闭
+func Start(t testing.TB, routerName string) *Server {
+	t.Helper()
+	s, err := NewServer(routerName)
+	if err != nil {
+		t.Fatalf("redistest: start %s: %s", routerName, err.Error())
+	}
+	t.Cleanup(s.Close)
+	return s
+}
@@ -0,0 +1,34 @@
+package redis_client
+
+// 通过代码注册的路由，不受配置文件热更新影响
+var registeredRouters = make(map[string]bool)
+
+// RegisterRedis 不使用配置文件直接注册路由，已存在的同名路由会被替换
+// 依赖该路由的 group 在下次获取时重建
+// 常用于测试，配合 redistest 使用内存中的 redis
+func RegisterRedis(name string, setting RedisSetting) error {
+	setting.RouterName = name
+	if err := setting.Validate(); err != nil {
+		return err
+	}
+	Manager.Lock()
+	defer Manager.Unlock()
+	stale := &staleRedis{}
+	Manager.detach(name, stale)
+	Manager.detachGroups(map[string]bool{name: true}, stale)
+	stale.close()
+	redisSettings[name] = &setting
+	registeredRouters[name] = true
+	return nil
+}
+
+// UnregisterRedis 移除路由并关闭客户端
+func UnregisterRedis(name string) {
+	Manager.Lock()
+	stale := &staleRedis{}
+	Manager.detach(name, stale)
+	Manager.detachGroups(map[string]bool{name: true}, stale)
+	delete(registeredRouters, name)
+	Manager.Unlock()
+	stale.close()
+}
@@ -0,0 +1,177 @@
+package redis_client
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/go-redis/redis"
+	"go.uber.org/zap"
+)
+
+// CloseGracePeriod 热更新后旧客户端延迟关闭的时间，等待执行中的命令完成
+// 长期运行的组件不要缓存 *redis.Client, 每次使用时通过 GetRedisClient 获取
+var CloseGracePeriod = 10 * time.Second
+
+// staleRedis 热更新时被替换下来，等待关闭的客户端
+type staleRedis struct {
+	clients  []*redis.Client
+	clusters []*redis.ClusterClient
+	watchers []*sentinelWatcher
+	routers  []*redisRouter
+}
+
+func (s *staleRedis) close() {
+	for _, r := range s.routers {
+		r.Close()
+	}
+	for _, w := range s.watchers {
+		w.Close()
+	}
+	for _, c := range s.clients {
+		c.Close()
+	}
+	for _, c := range s.clusters {
+		c.Close()
+	}
+}
+
+// detach 将路由从 Manager 中移除，调用方需持有锁
+func (m *mangers) detach(name string, stale *staleRedis) {
+	m.detachClient(name, stale)
+	delete(redisSettings, name)
+	delete(redisRawSettings, name)
+}
+
+// detachClient 只移除客户端, 保留配置, 下次获取时按配置重建
+func (m *mangers) detachClient(name string, stale *staleRedis) {
+	if c, ok := m.clients[name]; ok {
+		// group 的客户端共用主库的链接池, 由主库路由关闭
+		if _, isGroup := m.routers[name]; !isGroup {
+			stale.clients = append(stale.clients, c)
+		}
+		delete(m.clients, name)
+		delete(m.hooks, c)
+	}
+	if c, ok := m.clusters[name]; ok {
+		stale.clusters = append(stale.clusters, c)
+		delete(m.clusters, name)
+	}
+	if w, ok := m.watchers[name]; ok {
+		stale.watchers = append(stale.watchers, w)
+		delete(m.watchers, name)
+	}
+	if r, ok := m.routers[name]; ok {
+		stale.routers = append(stale.routers, r)
+		delete(m.routers, name)
+	}
+}
+
+// detachGroups 移除主从在 dirty 中的 group 客户端, 返回被移除的 group
+// group 复制的是旧的主从客户端, 主从被替换后需要重建
+func (m *mangers) detachGroups(dirty map[string]bool, stale *staleRedis) []string {
+	var groups []string
+	for name, setting := range redisSettings {
+		if dirty[name] || setting.Type != RedisTypeGroup {
+			continue
+		}
+		depends := dirty[setting.GetMaster()]
+		for _, slaver := range setting.GetSlavers() {
+			depends = depends || dirty[slaver]
+		}
+		if depends {
+			groups = append(groups, name)
+		}
+	}
+	for _, name := range groups {
+		m.detachClient(name, stale)
+	}
+	return groups
+}
+
+// reloadRedis 配置变更时只重建发生变化的路由，未变化的客户端继续使用
+// 配置解析失败时保留之前的配置
+func reloadRedis() {
+	conf := redisConf.GetConf()
+	if conf == nil {
+		logRedisReload(nil, nil, nil, nil, redisConf.Error)
+		return
+	}
+	newSettings := make(map[string]RedisSetting)
+	var added []string
+	for key := range conf.AllSettings() {
+		var setting RedisSetting
+		if err := conf.UnmarshalKey(key, &setting); err != nil {
+			logRedisReload(nil, nil, nil, nil, err)
+			return
+		}
+		if setting.RouterName == "" {
+			setting.RouterName = key
+		}
+		newSettings[setting.RouterName] = setting
+		if err := setting.Validate(); err != nil {
+			logRedisReload(nil, nil, nil, nil, err)
+			return
+		}
+	}
+
+	Manager.Lock()
+	defer Manager.Unlock()
+	var changed, removed, unchanged []string
+	dirty := make(map[string]bool)
+	for name, old := range redisRawSettings {
+		setting, ok := newSettings[name]
+		if !ok {
+			removed = append(removed, name)
+			dirty[name] = true
+		} else if !reflect.DeepEqual(old, setting) {
+			changed = append(changed, name)
+			dirty[name] = true
+		}
+	}
+	for name := range newSettings {
+		if _, ok := redisRawSettings[name]; !ok && !registeredRouters[name] {
+			added = append(added, name)
+		}
+	}
+
+	stale := &staleRedis{}
+	// group 依赖的主从发生变化时需要一起重建
+	rebuilt := make(map[string]bool)
+	for _, name := range Manager.detachGroups(dirty, stale) {
+		changed = append(changed, name)
+		rebuilt[name] = true
+	}
+	for name := range redisRawSettings {
+		if !dirty[name] && !rebuilt[name] {
+			unchanged = append(unchanged, name)
+		}
+	}
+	for name := range dirty {
+		Manager.detach(name, stale)
+	}
+	time.AfterFunc(CloseGracePeriod, stale.close)
+	logRedisReload(added, changed, removed, unchanged, nil)
+}
+
+func logRedisReload(added, changed, removed, unchanged []string, err error) {
+	logger, lerr := redisLogger()
+	if lerr != nil {
+		return
+	}
+	if err != nil {
+		logger.Error("reload",
+			zap.Namespace("properties"),
+			zap.String("reason", "redis conf parse failed, keep previous conf"),
+			zap.Error(err),
+		)
+		return
+	}
+	logger.Info("reload",
+		zap.Namespace("properties"),
+		zap.Strings("added", added),
+		zap.Strings("changed", changed),
+		zap.Strings("removed", removed),
+		zap.Strings("unchanged", unchanged),
+		zap.Duration("closeGracePeriod", CloseGracePeriod),
+	)
+}
@@ -0,0 +1,224 @@
+package redis_client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	RedisBalanceRoundRobin = "round_robin" // 轮询
+	RedisBalanceLatency    = "latency"     // 最小延迟
+)
+
+// 默认健康检查间隔
+const defaultHealthCheckFrequency = 5 * time.Second
+
+// 可以发往从库的只读命令
+var readOnlyCommands = map[string]struct{}{
+	"bitcount": {}, "bitpos": {}, "dbsize": {}, "dump": {}, "exists": {},
+	"geodist": {}, "geohash": {}, "geopos": {}, "georadius_ro": {}, "georadiusbymember_ro": {},
+	"get": {}, "getbit": {}, "getrange": {}, "hexists": {}, "hget": {}, "hgetall": {},
+	"hkeys": {}, "hlen": {}, "hmget": {}, "hscan": {}, "hstrlen": {}, "hvals": {},
+	"keys": {}, "lindex": {}, "llen": {}, "lrange": {}, "mget": {}, "pfcount": {},
+	"pttl": {}, "randomkey": {}, "scan": {}, "scard": {}, "sdiff": {}, "sinter": {},
+	"sismember": {}, "smembers": {}, "srandmember": {}, "sscan": {}, "strlen": {},
+	"sunion": {}, "ttl": {}, "type": {}, "xlen": {}, "xrange": {}, "xrevrange": {},
+	"zcard": {}, "zcount": {}, "zlexcount": {}, "zrange": {}, "zrangebylex": {},
+	"zrangebyscore": {}, "zrank": {}, "zrevrange": {}, "zrevrangebylex": {},
+	"zrevrangebyscore": {}, "zrevrank": {}, "zscan": {}, "zscore": {},
+}
+
+func isReadOnlyCommand(name string) bool {
+	_, ok := readOnlyCommands[strings.ToLower(name)]
+	return ok
+}
+
+// isConnError 判断是否为链接层面的错误，redis 返回的业务错误不算
+func isConnError(err error) bool {
+	if err == nil || err == redis.Nil {
+		return false
+	}
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	return strings.HasPrefix(err.Error(), "redis: connection pool timeout") ||
+		strings.HasPrefix(err.Error(), "redis: client is closed")
+}
+
+type redisReplica struct {
+	name    string
+	client  *redis.Client
+	process func(cmd redis.Cmder) error // 不记录日志的 process, 命令日志记录在 group 下
+	healthy int32
+	latency int64 // 纳秒, 平滑后的 ping 延迟
+}
+
+func (r *redisReplica) isHealthy() bool {
+	return atomic.LoadInt32(&r.healthy) == 1
+}
+
+func (r *redisReplica) setHealthy(ok bool) {
+	if ok {
+		atomic.StoreInt32(&r.healthy, 1)
+	} else {
+		atomic.StoreInt32(&r.healthy, 0)
+	}
+}
+
+func (r *redisReplica) observe(d time.Duration) {
+	old := atomic.LoadInt64(&r.latency)
+	if old == 0 {
+		atomic.StoreInt64(&r.latency, int64(d))
+		return
+	}
+	atomic.StoreInt64(&r.latency, (old*7+int64(d))/8)
+}
+
+// redisRouter 将一个 master 与多个 slaver 组合为一个逻辑路由
+// 只读命令发往健康的从库，其余命令以及从库不可用时发往主库
+type redisRouter struct {
+	name     string
+	master   *redis.Client
+	process  func(cmd redis.Cmder) error // 主库不记录日志的 process
+	replicas []*redisReplica
+	balance  string
+	next     uint32
+	stop     chan struct{}
+}
+
+func newRedisRouter(setting *RedisSetting, master *redis.Client, replicas []*redisReplica) *redisRouter {
+	r := &redisRouter{
+		name:     setting.RouterName,
+		master:   master,
+		replicas: replicas,
+		balance:  setting.GetBalance(),
+		stop:     make(chan struct{}),
+	}
+	for _, replica := range replicas {
+		replica.setHealthy(true)
+	}
+	go r.healthCheck(setting.GetHealthCheckFrequency())
+	return r
+}
+
+// pick 选择一个健康的从库，全部不可用时返回 nil
+func (r *redisRouter) pick() *redisReplica {
+	n := len(r.replicas)
+	if n == 0 {
+		return nil
+	}
+	if r.balance == RedisBalanceLatency {
+		var best *redisReplica
+		for _, replica := range r.replicas {
+			if !replica.isHealthy() {
+				continue
+			}
+			if best == nil || atomic.LoadInt64(&replica.latency) < atomic.LoadInt64(&best.latency) {
+				best = replica
+			}
+		}
+		return best
+	}
+	start := atomic.AddUint32(&r.next, 1)
+	for i := 0; i < n; i++ {
+		replica := r.replicas[(int(start)+i)%n]
+		if replica.isHealthy() {
+			return replica
+		}
+	}
+	return nil
+}
+
+func (r *redisRouter) route(cmd redis.Cmder) error {
+	if isReadOnlyCommand(cmd.Name()) {
+		if replica := r.pick(); replica != nil {
+			err := replica.process(cmd)
+			if !isConnError(err) {
+				return err
+			}
+			replica.setHealthy(false)
+		}
+	}
+	return r.process(cmd)
+}
+
+func (r *redisRouter) checkReplicas() {
+	for _, replica := range r.replicas {
+		start := time.Now()
+		err := replica.client.Ping().Err()
+		replica.setHealthy(err == nil)
+		if err == nil {
+			replica.observe(time.Since(start))
+		}
+	}
+}
+
+func (r *redisRouter) healthCheck(frequency time.Duration) {
+	r.checkReplicas()
+	ticker := time.NewTicker(frequency)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.checkReplicas()
+		}
+	}
+}
+
+func (r *redisRouter) Close() {
+	close(r.stop)
+}
+
+// newRedisGroupClient 创建读写分离的逻辑客户端，主从客户端复用 Manager 中已有的链接池
+// 逻辑客户端由主库客户端复制而来, 共用主库的链接池, pipeline 与事务不做路由，全部发往主库
+func newRedisGroupClient(setting *RedisSetting) (*redis.Client, *redisRouter, error) {
+	masterSetting, err := getRedisConf(setting.GetMaster())
+	if err != nil {
+		return nil, nil, err
+	}
+	if masterSetting.Type != RedisTypeMaster && masterSetting.Type != RedisTypeSentinel {
+		return nil, nil, fmt.Errorf("%s : redis group master type not master: %s",
+			setting.RouterName, masterSetting.Type)
+	}
+	if err := loadRedisManager([]*RedisSetting{masterSetting}); err != nil {
+		return nil, nil, err
+	}
+	replicas := make([]*redisReplica, 0, len(setting.GetSlavers()))
+	for _, name := range setting.GetSlavers() {
+		slaverSetting, err := getRedisConf(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !slaverSetting.GetReadOnly() {
+			return nil, nil, fmt.Errorf("%s : redis group slaver type not slaver: %s",
+				setting.RouterName, slaverSetting.Type)
+		}
+		if err := loadRedisManager([]*RedisSetting{slaverSetting}); err != nil {
+			return nil, nil, err
+		}
+		slaver := Manager.clients[slaverSetting.RouterName]
+		replicas = append(replicas, &redisReplica{name: name, client: slaver, process: Manager.hooks[slaver].process})
+	}
+	master := Manager.clients[masterSetting.RouterName]
+	router := newRedisRouter(setting, master, replicas)
+	// 单条命令经路由转发, 日志与耗时记录在 group 下; pipeline 直接使用主库的链接
+	client := master.WithContext(context.Background())
+	masterHook := Manager.hooks[master]
+	router.process = masterHook.process
+	h := &logHook{name: setting.RouterName, process: router.route, processPipeline: masterHook.processPipeline}
+	h.install(client, nil)
+	Manager.hooks[client] = h
+	return client, router, nil
+}
@@ -0,0 +1,35 @@
+package redis_client
+
+import "testing"
+
+func TestIsReadOnlyCommand(t *testing.T) {
+	if !isReadOnlyCommand("GET") || !isReadOnlyCommand("hgetall") {
+		t.Error("get and hgetall should be read only")
+	}
+	if isReadOnlyCommand("set") || isReadOnlyCommand("eval") {
+		t.Error("set and eval should not be read only")
+	}
+}
+
+func TestRedisRouterPick(t *testing.T) {
+	a := &redisReplica{name: "a", latency: 300}
+	b := &redisReplica{name: "b", latency: 100}
+	r := &redisRouter{replicas: []*redisReplica{a, b}}
+	if r.pick() != nil {
+		t.Error("pick should fall back to master when no replica is healthy")
+	}
+	a.setHealthy(true)
+	b.setHealthy(true)
+	first, second := r.pick(), r.pick()
+	if first == second {
+		t.Error("round robin should rotate replicas")
+	}
+	r.balance = RedisBalanceLatency
+	if r.pick() != b {
+		t.Error("latency balance should pick the fastest replica")
+	}
+	b.setHealthy(false)
+	if r.pick() != a {
+		t.Error("latency balance should skip unhealthy replicas")
+	}
+}
@@ -0,0 +1,124 @@
+package redis_client
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis"
+	"go.uber.org/zap"
+)
+
+// sentinel 发布的主从切换相关事件
+var sentinelEvents = []string{"+switch-master", "+failover-state-select-slave", "+sdown", "-sdown", "+odown", "-odown"}
+
+func newRedisFailoverClient(setting *RedisSetting) *redis.Client {
+	opt := &redis.FailoverOptions{
+		MasterName:         setting.GetMasterName(),
+		SentinelAddrs:      setting.GetSentinelAddrs(),
+		Password:           setting.getOptPassword(),
+		DB:                 setting.getOptDB(),
+		PoolSize:           setting.GetPoolSize(),
+		MinIdleConns:       setting.GetMinIdleConns(),
+		DialTimeout:        setting.GetDialTimeout(),
+		ReadTimeout:        setting.GetReadTimeout(),
+		WriteTimeout:       setting.GetWriteTimeout(),
+		IdleTimeout:        setting.GetIdleTimeout(),
+		IdleCheckFrequency: setting.GetIdleCheckFrequency(),
+		TLSConfig:          setting.GetTLSConfig(),
+		OnConnect:          setting.onConnect,
+	}
+	client := redis.NewFailoverClient(opt)
+	installLogHook(setting.RouterName, client)
+	return client
+}
+
+// sentinelWatcher 订阅 sentinel 事件, 订阅在后台进行, 不阻塞客户端的创建
+type sentinelWatcher struct {
+	setting *RedisSetting
+	mu      sync.Mutex
+	closed  bool
+	pubsub  *redis.PubSub
+}
+
+// watchSentinel 订阅第一个可用 sentinel 的事件，并写入 redis 日志
+func watchSentinel(setting *RedisSetting) *sentinelWatcher {
+	w := &sentinelWatcher{setting: setting}
+	go w.run()
+	return w
+}
+
+func (w *sentinelWatcher) run() {
+	for _, addr := range w.setting.GetSentinelAddrs() {
+		sentinel := redis.NewSentinelClient(&redis.Options{
+			Addr:         addr,
+			DialTimeout:  w.setting.GetDialTimeout(),
+			ReadTimeout:  w.setting.GetReadTimeout(),
+			WriteTimeout: w.setting.GetWriteTimeout(),
+		})
+		pubsub := sentinel.Subscribe(sentinelEvents...)
+		if _, err := pubsub.Receive(); err != nil {
+			logSentinelEvent(w.setting, addr, "subscribe_error", err.Error())
+			pubsub.Close()
+			sentinel.Close()
+			if w.isClosed() {
+				return
+			}
+			continue
+		}
+		w.mu.Lock()
+		if w.closed {
+			w.mu.Unlock()
+			pubsub.Close()
+			sentinel.Close()
+			return
+		}
+		w.pubsub = pubsub
+		w.mu.Unlock()
+		w.listen(addr, pubsub)
+		sentinel.Close()
+		return
+	}
+}
+
+// listen 直到 pubsub 被关闭
+func (w *sentinelWatcher) listen(addr string, pubsub *redis.PubSub) {
+	for msg := range pubsub.Channel() {
+		// payload 第一个字段为 master 名称 或 实例类型
+		if msg.Channel == "+switch-master" && !strings.HasPrefix(msg.Payload, w.setting.GetMasterName()+" ") {
+			continue
+		}
+		logSentinelEvent(w.setting, addr, msg.Channel, msg.Payload)
+	}
+}
+
+func (w *sentinelWatcher) isClosed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.closed
+}
+
+// Close 取消订阅, 后台的 sentinel 客户端随之关闭
+func (w *sentinelWatcher) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	if w.pubsub != nil {
+		return w.pubsub.Close()
+	}
+	return nil
+}
+
+func logSentinelEvent(setting *RedisSetting, addr, event, payload string) {
+	logger, err := redisLogger()
+	if err != nil {
+		return
+	}
+	logger.Warn("sentinel",
+		zap.Namespace("properties"),
+		zap.String("router", setting.RouterName),
+		zap.String("masterName", setting.GetMasterName()),
+		zap.String("sentinel", addr),
+		zap.String("sentinelEvent", event),
+		zap.String("payload", payload),
+	)
+}
@@ -0,0 +1,61 @@
+package redis_client
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// sentinel 无响应时不阻塞客户端创建
+func TestWatchSentinelNonBlocking(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	start := time.Now()
+	w := watchSentinel(&RedisSetting{RouterName: "sentinel_stall", MasterName: "mymaster",
+		SentinelAddrs: []string{ln.Addr().String()}, ReadTimeout: 200})
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("watchSentinel blocked for %s", d)
+	}
+	w.Close()
+}
+
+// 关闭后 sentinel 的链接随之关闭
+func TestWatchSentinelClose(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	w := watchSentinel(&RedisSetting{RouterName: "sentinel_close", MasterName: "mymaster",
+		SentinelAddrs: []string{mr.Addr()}})
+	waitConns := func(n int) bool {
+		for i := 0; i < 100; i++ {
+			if mr.CurrentConnectionCount() == n {
+				return true
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return false
+	}
+	if !waitConns(1) {
+		t.Fatal("watcher should subscribe")
+	}
+	w.Close()
+	if !waitConns(0) {
+		t.Errorf("sentinel connections should be closed, got %d", mr.CurrentConnectionCount())
+	}
+}
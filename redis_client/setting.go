@@ -1,27 +1,122 @@
 package redis_client
 
-import "time"
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/go-redis/redis"
+)
 
 const (
-	RedisTypeMaster  = "master"
-	RedisTypeSlaver  = "slaver"
-	RedisTypeCluster = "cluster"
+	RedisTypeMaster   = "master"
+	RedisTypeSlaver   = "slaver"
+	RedisTypeCluster  = "cluster"
+	RedisTypeSentinel = "sentinel"
+	RedisTypeGroup    = "group" // 读写分离, 由一个 master 和多个 slaver 组成
 )
 
 type RedisSetting struct {
-	RouterName         string
-	Type               string
-	Url                string // host:port
-	Password           string // password
-	DB                 int    // 数据库选择
-	PoolSize           int    // 链接池最大数量,  go-redis 默认 10
-	MinIdleConns       int    // 链接池最小存活链接数量， 默认无
-	DialTimeout        int    // 创建链接超时,  go-redis 默认 5 s
-	ReadTimeout        int    // 读超时，毫秒, go-redis 默认 3000 毫秒
-	WriteTimeout       int    // 写超时，毫秒, go-redis 默认 ReadTimeout
-	IdleTimeout        int    // 最后使用的空闲时间，后重新进行链接, go-redis 默认 5min
-	IdleCheckFrequency int    // 默认检测时间, go-redis 默认 1min
-	//Username           string // for redis 6.0
+	RouterName            string
+	Type                  string
+	Url                   string   // host:port
+	Password              string   // password
+	DB                    int      // 数据库选择
+	PoolSize              int      // 链接池最大数量,  go-redis 默认 10
+	MinIdleConns          int      // 链接池最小存活链接数量， 默认无
+	DialTimeout           int      // 创建链接超时,  go-redis 默认 5 s
+	ReadTimeout           int      // 读超时，毫秒, go-redis 默认 3000 毫秒
+	WriteTimeout          int      // 写超时，毫秒, go-redis 默认 ReadTimeout
+	IdleTimeout           int      // 最后使用的空闲时间，后重新进行链接, go-redis 默认 5min
+	IdleCheckFrequency    int      // 默认检测时间, go-redis 默认 1min
+	MasterName            string   // sentinel 监控的 master 名称
+	SentinelAddrs         []string // sentinel 节点地址列表, host:port
+	Master                string   // group 使用的 master 路由名
+	Slavers               []string // group 使用的 slaver 路由名列表
+	Balance               string   // group 从库选择策略, round_robin 或 latency, 默认 round_robin
+	HealthCheckFrequency  int      // group 从库健康检查间隔, 秒, 默认 5s
+	Username              string   // for redis 6.0 ACL
+	TLS                   bool     // 是否开启 TLS
+	TLSCAFile             string   // CA 证书路径, 为空时使用系统证书
+	TLSCertFile           string   // 客户端证书路径
+	TLSKeyFile            string   // 客户端私钥路径
+	TLSServerName         string   // 校验的服务端名称, 默认取 Url 中的 host
+	TLSInsecureSkipVerify bool     // 跳过证书校验, 仅用于开发环境
+	tlsConfig             *tls.Config
+}
+
+// Validate 校验配置，错误信息中包含路由名
+func (s *RedisSetting) Validate() error {
+	switch s.Type {
+	case RedisTypeMaster, RedisTypeSlaver, RedisTypeCluster:
+		if s.Url == "" {
+			return fmt.Errorf("redis conf %s: Url is required for type %s", s.RouterName, s.Type)
+		}
+	case RedisTypeSentinel:
+		if s.MasterName == "" {
+			return fmt.Errorf("redis conf %s: MasterName is required for type sentinel", s.RouterName)
+		}
+		if len(s.SentinelAddrs) == 0 {
+			return fmt.Errorf("redis conf %s: SentinelAddrs is required for type sentinel", s.RouterName)
+		}
+	case RedisTypeGroup:
+		if s.Master == "" {
+			return fmt.Errorf("redis conf %s: Master is required for type group", s.RouterName)
+		}
+		if len(s.Slavers) == 0 {
+			return fmt.Errorf("redis conf %s: Slavers is required for type group", s.RouterName)
+		}
+		if s.Balance != "" && s.Balance != RedisBalanceRoundRobin && s.Balance != RedisBalanceLatency {
+			return fmt.Errorf("redis conf %s: Balance not support: %s", s.RouterName, s.Balance)
+		}
+	default:
+		return fmt.Errorf("redis conf %s: type not support: %s", s.RouterName, s.Type)
+	}
+	if s.DB < 0 {
+		return fmt.Errorf("redis conf %s: DB must not be negative: %d", s.RouterName, s.DB)
+	}
+	if s.Username != "" && s.Password == "" {
+		return fmt.Errorf("redis conf %s: Password is required when Username is set", s.RouterName)
+	}
+	if (s.TLSCertFile == "") != (s.TLSKeyFile == "") {
+		return fmt.Errorf("redis conf %s: TLSCertFile and TLSKeyFile must be set together", s.RouterName)
+	}
+	if s.TLS {
+		tlsConfig, err := s.newTLSConfig()
+		if err != nil {
+			return fmt.Errorf("redis conf %s: %s", s.RouterName, err.Error())
+		}
+		s.tlsConfig = tlsConfig
+	}
+	return nil
+}
+
+func (s *RedisSetting) newTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         s.TLSServerName,
+		InsecureSkipVerify: s.TLSInsecureSkipVerify,
+	}
+	if s.TLSCAFile != "" {
+		ca, err := ioutil.ReadFile(s.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read TLSCAFile failed: %s", err.Error())
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("TLSCAFile has no valid certificate: %s", s.TLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if s.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(s.TLSCertFile, s.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load TLSCertFile/TLSKeyFile failed: %s", err.Error())
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
 }
 
 func (s *RedisSetting) GetReadOnly() bool {
@@ -35,14 +130,90 @@ func (s *RedisSetting) GetAddr() string {
 	return s.Url
 }
 
-//func (s *RedisSetting) GetUserName() string {
-//	return s.Username
-//}
+func (s *RedisSetting) GetMasterName() string {
+	return s.MasterName
+}
+
+func (s *RedisSetting) GetSentinelAddrs() []string {
+	return s.SentinelAddrs
+}
+
+func (s *RedisSetting) GetMaster() string {
+	return s.Master
+}
+
+func (s *RedisSetting) GetSlavers() []string {
+	return s.Slavers
+}
+
+func (s *RedisSetting) GetBalance() string {
+	if s.Balance != RedisBalanceLatency {
+		s.Balance = RedisBalanceRoundRobin
+	}
+	return s.Balance
+}
+
+func (s *RedisSetting) GetHealthCheckFrequency() time.Duration {
+	if s.HealthCheckFrequency <= 0 {
+		return defaultHealthCheckFrequency
+	}
+	return time.Duration(s.HealthCheckFrequency) * time.Second
+}
+
+func (s *RedisSetting) GetUserName() string {
+	return s.Username
+}
 
 func (s *RedisSetting) GetPassword() string {
 	return s.Password
 }
 
+func (s *RedisSetting) GetDB() int {
+	return s.DB
+}
+
+// GetTLSConfig 未开启 TLS 时返回 nil
+func (s *RedisSetting) GetTLSConfig() *tls.Config {
+	if !s.TLS {
+		return nil
+	}
+	if s.tlsConfig == nil {
+		s.tlsConfig, _ = s.newTLSConfig()
+	}
+	return s.tlsConfig
+}
+
+// getOptPassword 和 getOptDB 用于 go-redis 的 Options
+// go-redis v6 不支持 ACL 用户名，设置 Username 时在 OnConnect 中完成 AUTH 与 SELECT
+func (s *RedisSetting) getOptPassword() string {
+	if s.Username != "" {
+		return ""
+	}
+	return s.Password
+}
+
+func (s *RedisSetting) getOptDB() int {
+	if s.Username != "" {
+		return 0
+	}
+	return s.DB
+}
+
+func (s *RedisSetting) onConnect(conn *redis.Conn) error {
+	if s.Username != "" {
+		if err := conn.Do("auth", s.Username, s.Password).Err(); err != nil {
+			return err
+		}
+		if s.DB > 0 {
+			if err := conn.Select(s.DB).Err(); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := conn.Ping().Result()
+	return err
+}
+
 func (s *RedisSetting) GetPoolSize() int {
 	if s.PoolSize <= 0 {
 		s.PoolSize = 0
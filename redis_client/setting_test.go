@@ -0,0 +1,44 @@
+package redis_client
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedisSettingValidate(t *testing.T) {
+	cases := []struct {
+		setting RedisSetting
+		err     string
+	}{
+		{RedisSetting{RouterName: "a", Type: RedisTypeMaster, Url: "127.0.0.1:6379", DB: 2}, ""},
+		{RedisSetting{RouterName: "b", Type: "unknown"}, "redis conf b: type not support"},
+		{RedisSetting{RouterName: "c", Type: RedisTypeMaster}, "redis conf c: Url is required"},
+		{RedisSetting{RouterName: "d", Type: RedisTypeSentinel, MasterName: "m"}, "redis conf d: SentinelAddrs is required"},
+		{RedisSetting{RouterName: "e", Type: RedisTypeMaster, Url: "x", Username: "u"}, "redis conf e: Password is required"},
+		{RedisSetting{RouterName: "f", Type: RedisTypeMaster, Url: "x", TLS: true, TLSCAFile: "/not/exists"}, "redis conf f: read TLSCAFile failed"},
+		{RedisSetting{RouterName: "g", Type: RedisTypeMaster, Url: "x", TLSCertFile: "cert.pem"}, "redis conf g: TLSCertFile and TLSKeyFile"},
+	}
+	for _, c := range cases {
+		err := c.setting.Validate()
+		if c.err == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %s", c.setting.RouterName, err)
+			}
+			continue
+		}
+		if err == nil || !strings.HasPrefix(err.Error(), c.err) {
+			t.Errorf("%s: expected error %q, got %v", c.setting.RouterName, c.err, err)
+		}
+	}
+}
+
+func TestRedisSettingACL(t *testing.T) {
+	s := &RedisSetting{Password: "p", DB: 3}
+	if s.getOptPassword() != "p" || s.getOptDB() != 3 {
+		t.Error("without username password and db should be passed to go-redis")
+	}
+	s.Username = "u"
+	if s.getOptPassword() != "" || s.getOptDB() != 0 {
+		t.Error("with username auth and select should be done on connect")
+	}
+}
@@ -0,0 +1,258 @@
+// 基于 redis_client 的主节点选举
+// 多个副本竞争同一个租约 key, 持有者定期续期, 续期失败或主动退出时让出
+package redis_leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/legenove/nano-server-sdk/redis_client"
+	"github.com/legenove/utils"
+)
+
+const keyPrefix = "nano:leader:"
+
+// 租约以毫秒续期并预留时钟漂移, 过短的租期无法在到期前完成续期
+const minTTL = 10 * time.Millisecond
+
+// 校验身份后续期
+var renewScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// 校验身份后删除
+var resignScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0`)
+
+type options struct {
+	ttl       time.Duration
+	identity  string
+	onElected func(ctx context.Context)
+	onRevoked func()
+}
+
+type Option func(*options)
+
+// WithTTL 租期, 每 1/3 租期续期或竞选一次, 默认 15s
+func WithTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.ttl = ttl
+	}
+}
+
+// WithIdentity 当前副本的身份, 默认 hostname-pid
+func WithIdentity(identity string) Option {
+	return func(o *options) {
+		o.identity = identity
+	}
+}
+
+// WithOnElected 成为主节点时回调, ctx 在失去主节点身份时被取消
+func WithOnElected(f func(ctx context.Context)) Option {
+	return func(o *options) {
+		o.onElected = f
+	}
+}
+
+// WithOnRevoked 失去主节点身份时回调
+func WithOnRevoked(f func()) Option {
+	return func(o *options) {
+		o.onRevoked = f
+	}
+}
+
+// Elector 参与名为 name 的选举
+type Elector struct {
+	router string
+	name   string
+	key    string
+	opt    options
+
+	mu       sync.RWMutex
+	leader   string
+	isLeader bool
+	since    time.Time
+	running  bool
+}
+
+func NewElector(routerName, name string, opts ...Option) *Elector {
+	host, _ := os.Hostname()
+	opt := options{
+		ttl:      15 * time.Second,
+		identity: fmt.Sprintf("%s-%d", host, os.Getpid()),
+	}
+	for _, o := range opts {
+		o(&opt)
+	}
+	e := &Elector{
+		router: routerName,
+		name:   name,
+		key:    utils.ConcatenateStrings(keyPrefix, name),
+		opt:    opt,
+	}
+	register(e)
+	return e
+}
+
+func (e *Elector) Name() string {
+	return e.name
+}
+
+func (e *Elector) Identity() string {
+	return e.opt.identity
+}
+
+// IsLeader 当前副本是否为主节点
+func (e *Elector) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.isLeader
+}
+
+// Leader 最近一次观察到的主节点身份, 未知时为空
+func (e *Elector) Leader() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.leader
+}
+
+// Run 参与选举, 阻塞直到 ctx 结束, 结束时如果是主节点则主动让出
+// 每次操作都从 redis_client 获取客户端, 配置热更新后使用新的客户端
+func (e *Elector) Run(ctx context.Context) error {
+	if e.opt.ttl < minTTL {
+		return fmt.Errorf("redis_leader: elector %s ttl %s is less than %s", e.name, e.opt.ttl, minTTL)
+	}
+	if _, err := redis_client.GetRedisClient(e.router); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	if e.running {
+		e.mu.Unlock()
+		return errors.New("redis_leader: elector " + e.name + " already running")
+	}
+	e.running = true
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		e.running = false
+		e.mu.Unlock()
+	}()
+
+	interval := e.opt.ttl / 3
+	for {
+		client, err := redis_client.GetRedisClient(e.router)
+		var ok bool
+		if err == nil {
+			ok, err = client.SetNX(e.key, e.opt.identity, e.opt.ttl).Result()
+		}
+		if err == nil && ok {
+			e.lead(ctx)
+		} else {
+			var leader string
+			if client != nil {
+				leader, _ = client.Get(e.key).Result()
+			}
+			e.setLeader(leader, false)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// lead 持有租约期间定期续期, 直到租约丢失或 ctx 结束
+func (e *Elector) lead(ctx context.Context) {
+	e.setLeader(e.opt.identity, true)
+	lctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	if e.opt.onElected != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.opt.onElected(lctx)
+		}()
+	}
+	defer func() {
+		cancel()
+		wg.Wait()
+		e.setLeader("", false)
+		if e.opt.onRevoked != nil {
+			e.opt.onRevoked()
+		}
+	}()
+
+	ttl := int64(e.opt.ttl / time.Millisecond)
+	// 租约到期前 (预留时钟漂移) 没有续期成功则取消 lctx, 续期请求阻塞时也能及时让出
+	drift := e.opt.ttl/100 + 2*time.Millisecond
+	deadline := time.AfterFunc(e.opt.ttl-drift, func() {
+		cancel()
+		e.setLeader("", false)
+	})
+	defer deadline.Stop()
+	ticker := time.NewTicker(e.opt.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			e.resign()
+			return
+		case <-lctx.Done():
+			// ctx 结束时 lctx 同时结束, 两个分支都可能被选中
+			if ctx.Err() != nil {
+				e.resign()
+			}
+			return
+		case <-ticker.C:
+		}
+		var res int64
+		sent := time.Now()
+		client, err := redis_client.GetRedisClient(e.router)
+		if err == nil {
+			res, err = renewScript.Run(client, []string{e.key}, e.opt.identity, ttl).Int64()
+		}
+		if lctx.Err() != nil {
+			if ctx.Err() != nil {
+				e.resign()
+			}
+			return
+		}
+		if err == nil && res == 1 {
+			// 租约从发出续期请求时开始计算
+			deadline.Reset(e.opt.ttl - drift - time.Since(sent))
+			continue
+		}
+		// 续期出错时租约可能仍然有效, 继续重试直到 deadline
+		if err == nil {
+			return
+		}
+	}
+}
+
+// resign 主动删除自己持有的租约
+func (e *Elector) resign() {
+	if client, err := redis_client.GetRedisClient(e.router); err == nil {
+		resignScript.Run(client, []string{e.key}, e.opt.identity)
+	}
+}
+
+func (e *Elector) setLeader(leader string, isLeader bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.isLeader != isLeader {
+		e.since = time.Now()
+	}
+	e.leader = leader
+	e.isLeader = isLeader
+}
@@ -0,0 +1,166 @@
+package redis_leader
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/legenove/nano-server-sdk/redis_client"
+	"github.com/legenove/nano-server-sdk/redis_client/redistest"
+)
+
+// stallProxy 转发到 redis, pause 后请求不再送达, 模拟续期请求阻塞
+type stallProxy struct {
+	ln     net.Listener
+	target string
+	gate   sync.RWMutex
+}
+
+func startStallProxy(t *testing.T, target string) *stallProxy {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &stallProxy{ln: ln, target: target}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			up, err := net.Dial("tcp", target)
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			go p.pipe(up, conn)
+			go p.pipe(conn, up)
+		}
+	}()
+	return p
+}
+
+func (p *stallProxy) pipe(dst, src net.Conn) {
+	defer dst.Close()
+	defer src.Close()
+	buf := make([]byte, 4096)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			p.gate.RLock()
+			_, werr := dst.Write(buf[:n])
+			p.gate.RUnlock()
+			if werr != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (p *stallProxy) pause()  { p.gate.Lock() }
+func (p *stallProxy) resume() { p.gate.Unlock() }
+
+// 续期请求阻塞时, 在租约到期前取消 onElected 的 ctx, 其他副本随后才能当选
+func TestLeaderDeadline(t *testing.T) {
+	mr := redistest.Start(t, "leader_b")
+	proxy := startStallProxy(t, mr.Addr())
+	err := redis_client.RegisterRedis("leader_a", redis_client.RedisSetting{
+		Type: redis_client.RedisTypeMaster,
+		Url:  proxy.ln.Addr().String(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer redis_client.UnregisterRedis("leader_a")
+
+	const ttl = 300 * time.Millisecond
+	elected := make(chan context.Context, 1)
+	a := NewElector("leader_a", "deadline", WithTTL(ttl), WithIdentity("a"),
+		WithOnElected(func(ctx context.Context) { elected <- ctx }))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	var lctx context.Context
+	select {
+	case lctx = <-elected:
+	case <-time.After(time.Second):
+		t.Fatal("a should be elected")
+	}
+	proxy.pause()
+	paused := time.Now()
+	select {
+	case <-lctx.Done():
+	case <-time.After(ttl + 100*time.Millisecond):
+		proxy.resume()
+		t.Fatal("leader ctx should be cancelled before the lease expires")
+	}
+	if since := time.Since(paused); since >= ttl {
+		t.Errorf("leader ctx cancelled %s after the last renew, lease is %s", since, ttl)
+	}
+	if a.IsLeader() {
+		t.Error("a should not report itself as leader after the deadline")
+	}
+
+	// 租约在 redis 中到期后, 其他副本当选
+	mr.FastForward(ttl)
+	b := NewElector("leader_b", "deadline", WithTTL(ttl), WithIdentity("b"))
+	bctx, bcancel := context.WithCancel(context.Background())
+	bdone := make(chan struct{})
+	go func() {
+		defer close(bdone)
+		b.Run(bctx)
+	}()
+	for i := 0; i < 100 && !b.IsLeader(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !b.IsLeader() {
+		t.Error("b should be elected after the lease expires")
+	}
+	bcancel()
+	<-bdone
+	proxy.resume()
+}
+
+// ctx 结束时主节点主动让出租约
+func TestLeaderResign(t *testing.T) {
+	mr := redistest.Start(t, "leader_resign")
+	e := NewElector("leader_resign", "resign", WithTTL(time.Second), WithIdentity("a"))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- e.Run(ctx) }()
+	for i := 0; i < 100 && !e.IsLeader(); i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if !e.IsLeader() {
+		t.Fatal("elector should be elected")
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if mr.Exists(keyPrefix + "resign") {
+		t.Error("lease should be released when ctx is cancelled")
+	}
+}
+
+func TestLeaderInvalidTTL(t *testing.T) {
+	redistest.Start(t, "leader_ttl")
+	e := NewElector("leader_ttl", "ttl", WithTTL(time.Nanosecond))
+	if err := e.Run(context.Background()); err == nil {
+		t.Error("Run should reject a ttl shorter than minTTL")
+	}
+}
@@ -0,0 +1,50 @@
+package redis_leader
+
+import (
+	"expvar"
+	"sync"
+	"time"
+)
+
+// 所有选举, 用于 expvar 统计
+var electors = map[string]*Elector{}
+var electorsMu sync.Mutex
+
+func register(e *Elector) {
+	electorsMu.Lock()
+	defer electorsMu.Unlock()
+	electors[e.name] = e
+}
+
+type ElectorStats struct {
+	Identity string `json:"identity"`
+	Leader   string `json:"leader"`
+	IsLeader bool   `json:"is_leader"`
+	Since    string `json:"since,omitempty"`
+}
+
+func (e *Elector) Stats() ElectorStats {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	stats := ElectorStats{
+		Identity: e.opt.identity,
+		Leader:   e.leader,
+		IsLeader: e.isLeader,
+	}
+	if !e.since.IsZero() {
+		stats.Since = e.since.Format(time.RFC3339)
+	}
+	return stats
+}
+
+func init() {
+	expvar.Publish("leader", expvar.Func(func() interface{} {
+		electorsMu.Lock()
+		defer electorsMu.Unlock()
+		res := make(map[string]ElectorStats, len(electors))
+		for name, e := range electors {
+			res[name] = e.Stats()
+		}
+		return res
+	}))
+}
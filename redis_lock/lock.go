@@ -0,0 +1,271 @@
+// 基于 redis_client 的分布式锁
+// 单节点使用 SET NX PX, 多节点使用 Redlock 算法
+package redis_lock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/legenove/nano-server-sdk/redis_client"
+	"github.com/legenove/random"
+)
+
+var (
+	ErrNotObtained = errors.New("redis_lock: lock not obtained")
+	ErrLockNotHeld = errors.New("redis_lock: lock not held")
+)
+
+// 校验 token 后删除
+var unlockScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0`)
+
+// 校验 token 后续期
+var extendScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0`)
+
+type options struct {
+	ttl           time.Duration
+	retryInterval time.Duration
+	autoExtend    bool
+}
+
+type Option func(*options)
+
+// WithTTL 锁的租期，默认 10s
+func WithTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.ttl = ttl
+	}
+}
+
+// WithRetryInterval Lock 获取失败后的重试间隔，默认 100ms
+func WithRetryInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.retryInterval = d
+	}
+}
+
+// WithAutoExtend 持有期间是否自动续期，默认开启
+func WithAutoExtend(b bool) Option {
+	return func(o *options) {
+		o.autoExtend = b
+	}
+}
+
+// Locker 只保存路由名, 每次操作时从 redis_client 获取客户端, 配置热更新后使用新的客户端
+type Locker struct {
+	routers []string
+	quorum  int
+	opt     options
+}
+
+// NewLocker 使用一个 redis 路由创建锁
+func NewLocker(routerName string, opts ...Option) (*Locker, error) {
+	return NewRedLocker([]string{routerName}, opts...)
+}
+
+// NewRedLocker 使用多个相互独立的 redis 路由创建 Redlock, 多数节点加锁成功才算获取到锁
+func NewRedLocker(routerNames []string, opts ...Option) (*Locker, error) {
+	if len(routerNames) == 0 {
+		return nil, errors.New("redis_lock: no redis router")
+	}
+	for _, name := range routerNames {
+		if _, err := redis_client.GetRedisClient(name); err != nil {
+			return nil, err
+		}
+	}
+	return newLocker(routerNames, opts...)
+}
+
+func newLocker(routerNames []string, opts ...Option) (*Locker, error) {
+	opt := options{
+		ttl:           10 * time.Second,
+		retryInterval: 100 * time.Millisecond,
+		autoExtend:    true,
+	}
+	for _, o := range opts {
+		o(&opt)
+	}
+	if opt.ttl <= 0 {
+		return nil, errors.New("redis_lock: ttl must be positive")
+	}
+	if opt.retryInterval <= 0 {
+		return nil, errors.New("redis_lock: retry interval must be positive")
+	}
+	return &Locker{routers: routerNames, quorum: len(routerNames)/2 + 1, opt: opt}, nil
+}
+
+// TryLock 尝试获取一次锁，失败时返回 ErrNotObtained
+func (l *Locker) TryLock(ctx context.Context, key string) (*Lock, error) {
+	token := random.UuidV4()
+	ok, err := l.acquire(key, token)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotObtained
+	}
+	return l.newLock(ctx, key, token), nil
+}
+
+// Lock 阻塞直到获取到锁或 ctx 结束
+func (l *Locker) Lock(ctx context.Context, key string) (*Lock, error) {
+	for {
+		lock, err := l.TryLock(ctx, key)
+		if err != ErrNotObtained {
+			return lock, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(l.opt.retryInterval):
+		}
+	}
+}
+
+func (l *Locker) acquire(key, token string) (bool, error) {
+	start := time.Now()
+	var n int
+	var lastErr error
+	for _, name := range l.routers {
+		client, err := redis_client.GetRedisClient(name)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		ok, err := client.SetNX(key, token, l.opt.ttl).Result()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if ok {
+			n++
+		}
+	}
+	// 时钟漂移，参考 Redlock 算法
+	drift := l.opt.ttl/100 + 2*time.Millisecond
+	if n >= l.quorum && time.Since(start) < l.opt.ttl-drift {
+		return true, nil
+	}
+	l.release(key, token)
+	if n == 0 && lastErr != nil && len(l.routers) == 1 {
+		return false, lastErr
+	}
+	return false, nil
+}
+
+func (l *Locker) extend(key, token string) bool {
+	var n int
+	for _, name := range l.routers {
+		client, err := redis_client.GetRedisClient(name)
+		if err != nil {
+			continue
+		}
+		res, err := extendScript.Run(client, []string{key}, token, int64(l.opt.ttl/time.Millisecond)).Int64()
+		if err == nil && res == 1 {
+			n++
+		}
+	}
+	return n >= l.quorum
+}
+
+func (l *Locker) release(key, token string) int {
+	var n int
+	for _, name := range l.routers {
+		client, err := redis_client.GetRedisClient(name)
+		if err != nil {
+			continue
+		}
+		res, err := unlockScript.Run(client, []string{key}, token).Int64()
+		if err == nil && res == 1 {
+			n++
+		}
+	}
+	return n
+}
+
+func (l *Locker) newLock(ctx context.Context, key, token string) *Lock {
+	lctx, cancel := context.WithCancel(ctx)
+	lock := &Lock{
+		locker: l,
+		key:    key,
+		token:  token,
+		ctx:    lctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	if l.opt.autoExtend {
+		go lock.keepAlive()
+	} else {
+		close(lock.done)
+	}
+	return lock
+}
+
+// Lock 持有中的锁
+type Lock struct {
+	locker *Locker
+	key    string
+	token  string
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+}
+
+func (lk *Lock) Key() string {
+	return lk.key
+}
+
+func (lk *Lock) Token() string {
+	return lk.token
+}
+
+// Context 在锁丢失、释放或者获取锁时传入的 ctx 结束时被取消
+func (lk *Lock) Context() context.Context {
+	return lk.ctx
+}
+
+// keepAlive 每 1/3 租期续期一次，续期失败时取消 Context
+// 获取锁时传入的 ctx 结束后释放锁
+func (lk *Lock) keepAlive() {
+	defer close(lk.done)
+	ticker := time.NewTicker(lk.locker.opt.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-lk.ctx.Done():
+			lk.once.Do(func() {
+				lk.locker.release(lk.key, lk.token)
+			})
+			return
+		case <-ticker.C:
+			if !lk.locker.extend(lk.key, lk.token) {
+				lk.cancel()
+				return
+			}
+		}
+	}
+}
+
+// Unlock 释放锁，锁已过期或被他人持有时返回 ErrLockNotHeld
+func (lk *Lock) Unlock() error {
+	err := ErrLockNotHeld
+	lk.once.Do(func() {
+		if lk.locker.release(lk.key, lk.token) >= lk.locker.quorum {
+			err = nil
+		}
+	})
+	lk.cancel()
+	<-lk.done
+	return err
+}
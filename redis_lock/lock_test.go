@@ -0,0 +1,176 @@
+package redis_lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/legenove/nano-server-sdk/redis_client"
+	"github.com/legenove/nano-server-sdk/redis_client/redistest"
+)
+
+func TestLock(t *testing.T) {
+	redistest.Start(t, "lock_redis")
+	locker, err := NewLocker("lock_redis", WithAutoExtend(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	lock, err := locker.TryLock(ctx, "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := locker.TryLock(ctx, "job"); err != ErrNotObtained {
+		t.Fatalf("second TryLock should fail, got %v", err)
+	}
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := lock.Unlock(); err != ErrLockNotHeld {
+		t.Fatalf("second Unlock should return ErrLockNotHeld, got %v", err)
+	}
+	lock, err = locker.TryLock(ctx, "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock.Unlock()
+}
+
+func TestInvalidOptions(t *testing.T) {
+	redistest.Start(t, "lock_options_redis")
+	if _, err := NewLocker("lock_options_redis", WithTTL(0)); err == nil {
+		t.Error("zero ttl should be rejected")
+	}
+	if _, err := NewLocker("lock_options_redis", WithRetryInterval(-time.Second)); err == nil {
+		t.Error("negative retry interval should be rejected")
+	}
+}
+
+// 路由被替换后旧客户端已关闭, Locker 需要使用新的客户端
+func TestLockAfterReload(t *testing.T) {
+	mr := redistest.Start(t, "lock_reload_redis")
+	locker, err := NewLocker("lock_reload_redis", WithAutoExtend(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = redis_client.RegisterRedis("lock_reload_redis", redis_client.RedisSetting{
+		Type: redis_client.RedisTypeMaster,
+		Url:  mr.Addr(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock, err := locker.TryLock(context.Background(), "job")
+	if err != nil {
+		t.Fatalf("TryLock after reload: %v", err)
+	}
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 多数节点被他人持有时获取失败, 且释放已经获取的节点
+func TestRedLockQuorum(t *testing.T) {
+	a := redistest.Start(t, "redlock_a")
+	b := redistest.Start(t, "redlock_b")
+	c := redistest.Start(t, "redlock_c")
+	locker, err := NewRedLocker([]string{"redlock_a", "redlock_b", "redlock_c"}, WithAutoExtend(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	b.Set("job", "other")
+	c.Set("job", "other")
+	if _, err := locker.TryLock(ctx, "job"); err != ErrNotObtained {
+		t.Fatalf("TryLock without quorum should fail, got %v", err)
+	}
+	if a.Exists("job") {
+		t.Error("partially acquired node should be released")
+	}
+
+	c.Del("job")
+	lock, err := locker.TryLock(ctx, "job")
+	if err != nil {
+		t.Fatalf("TryLock with quorum: %v", err)
+	}
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if a.Exists("job") || c.Exists("job") {
+		t.Error("Unlock should release acquired nodes")
+	}
+	if v, _ := b.Get("job"); v != "other" {
+		t.Errorf("Unlock should not release other holders, got %q", v)
+	}
+}
+
+func TestLockExtend(t *testing.T) {
+	mr := redistest.Start(t, "lock_extend_redis")
+	locker, err := NewLocker("lock_extend_redis", WithTTL(time.Second), WithAutoExtend(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock, err := locker.TryLock(context.Background(), "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Unlock()
+	mr.FastForward(500 * time.Millisecond)
+	if locker.extend("job", "wrong") {
+		t.Error("extend with wrong token should fail")
+	}
+	if ttl := mr.TTL("job"); ttl > 500*time.Millisecond {
+		t.Errorf("wrong token should not extend, ttl %s", ttl)
+	}
+	if !locker.extend("job", lock.Token()) {
+		t.Error("extend with owner token should succeed")
+	}
+	if ttl := mr.TTL("job"); ttl != time.Second {
+		t.Errorf("extend should reset ttl, got %s", ttl)
+	}
+}
+
+// 锁过期并被他人获取后, 原持有者的 Unlock 不删除他人的锁, 自动续期失败时取消 Context
+func TestLockAfterExpiry(t *testing.T) {
+	mr := redistest.Start(t, "lock_expiry_redis")
+	ctx := context.Background()
+	locker, err := NewLocker("lock_expiry_redis", WithTTL(300*time.Millisecond), WithAutoExtend(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock, err := locker.TryLock(ctx, "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr.FastForward(time.Second)
+	other, err := locker.TryLock(ctx, "job")
+	if err != nil {
+		t.Fatalf("TryLock after expiry: %v", err)
+	}
+	if err := lock.Unlock(); err != ErrLockNotHeld {
+		t.Errorf("Unlock after expiry should return ErrLockNotHeld, got %v", err)
+	}
+	if v, _ := mr.Get("job"); v != other.Token() {
+		t.Error("Unlock after expiry should not release other holders")
+	}
+	other.Unlock()
+
+	auto, err := NewLocker("lock_expiry_redis", WithTTL(300*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock, err = auto.TryLock(ctx, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr.FastForward(time.Second)
+	mr.Set("auto", "other")
+	select {
+	case <-lock.Context().Done():
+	case <-time.After(time.Second):
+		t.Error("lock context should be cancelled after the lock is lost")
+	}
+	if err := lock.Unlock(); err != ErrLockNotHeld {
+		t.Errorf("Unlock of a lost lock should return ErrLockNotHeld, got %v", err)
+	}
+}
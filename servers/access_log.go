@@ -0,0 +1,142 @@
+package servers
+
+import (
+	"context"
+	"hash/fnv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/legenove/cocore"
+)
+
+// 请求头带上 Nano-Debug: 1 时该请求的 access 日志总是输出, 并透传给下游服务
+const SERVER_INCOME_DEBUG = "Nano-Debug"
+
+// AccessLogRule access 日志采样规则, 按顺序匹配第一条
+// Func 为请求路由或 rpc 方法, 以 * 结尾时按前缀匹配; FromApp 为调用方服务名; 为空时匹配全部
+type AccessLogRule struct {
+	Func    string `json:"func" mapstructure:"func"`
+	FromApp string `json:"from_app" mapstructure:"from_app"`
+	Rate    int    `json:"rate" mapstructure:"rate"`       // 采样比例 0-100
+	Payload bool   `json:"payload" mapstructure:"payload"` // 记录脱敏后的请求与返回内容
+}
+
+// AccessLogSetting app 配置中的 [access_log]
+//
+//	[access_log]
+//	slow_ms = 500
+//	[[access_log.rules]]
+//	func = "/user.UserService/*"
+//	rate = 10
+//	payload = true
+type AccessLogSetting struct {
+	SlowMs          int64           `json:"slow_ms" mapstructure:"slow_ms"`                     // 超过该耗时的请求总是记录, 0 为不启用
+	PayloadMaxBytes int             `json:"payload_max_bytes" mapstructure:"payload_max_bytes"` // 请求与返回内容的最大长度, 默认 2048
+	RedactFields    []string        `json:"redact_fields" mapstructure:"redact_fields"`         // 在默认字段之外需要脱敏的字段名
+	Rules           []AccessLogRule `json:"rules" mapstructure:"rules"`
+}
+
+type accessLogSampler struct {
+	rate    int // 没有规则匹配时使用 OPEN_ACCESS_LOG
+	slow    time.Duration
+	rules   []AccessLogRule
+	payload *payloadPolicy
+}
+
+var accessSampler atomic.Value
+
+func loadAccessLogSetting() {
+	var setting AccessLogSetting
+	if cocore.App != nil && cocore.App.AppConf != nil {
+		if conf := cocore.App.AppConf.GetConf(); conf != nil {
+			conf.UnmarshalKey("access_log", &setting)
+		}
+	}
+	setAccessLogSetting(OpenAccessLog, setting)
+}
+
+func setAccessLogSetting(rate int, setting AccessLogSetting) {
+	accessSampler.Store(&accessLogSampler{
+		rate:    rate,
+		slow:    time.Duration(setting.SlowMs) * time.Millisecond,
+		rules:   setting.Rules,
+		payload: newPayloadPolicy(setting.PayloadMaxBytes, setting.RedactFields),
+	})
+}
+
+func (r *AccessLogRule) match(funcName, fromApp string) bool {
+	if r.FromApp != "" && r.FromApp != fromApp {
+		return false
+	}
+	if r.Func == "" || r.Func == funcName {
+		return true
+	}
+	return strings.HasSuffix(r.Func, "*") && strings.HasPrefix(funcName, r.Func[:len(r.Func)-1])
+}
+
+func (s *accessLogSampler) matchRule(ctx context.Context) *AccessLogRule {
+	funcName, fromApp := GetServerRequestFunc(ctx), GetServerName(ctx)
+	for i := range s.rules {
+		if s.rules[i].match(funcName, fromApp) {
+			return &s.rules[i]
+		}
+	}
+	return nil
+}
+
+// sampled 按请求 id 的哈希决定, 同一个请求在各个服务中的采样结果一致
+func sampled(requestId string, rate int) bool {
+	if rate <= 0 {
+		return false
+	}
+	if rate >= 100 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(requestId))
+	return int(h.Sum32()%100) < rate
+}
+
+// IsDebugRequest 请求是否带有 Nano-Debug 标记
+func IsDebugRequest(ctx context.Context) bool {
+	r := GetServerIncomeByKey(SERVER_INCOME_DEBUG, ctx)
+	if len(r) == 0 {
+		return false
+	}
+	switch strings.ToLower(r[0]) {
+	case "", "0", "false":
+		return false
+	}
+	return true
+}
+
+// ShouldAccessLog 是否记录本次请求的 access 日志
+// 失败, 慢请求以及带 Nano-Debug 标记的请求总是记录, 其余按规则采样
+func ShouldAccessLog(ctx context.Context, duration time.Duration, failed bool) bool {
+	if failed || IsDebugRequest(ctx) {
+		return true
+	}
+	s, _ := accessSampler.Load().(*accessLogSampler)
+	if s == nil {
+		return true
+	}
+	if s.slow > 0 && duration >= s.slow {
+		return true
+	}
+	rate := s.rate
+	if rule := s.matchRule(ctx); rule != nil {
+		rate = rule.Rate
+	}
+	return sampled(GetRequestId(ctx), rate)
+}
+
+// CapturePayload 当前请求匹配的规则是否开启了 payload 记录
+func CapturePayload(ctx context.Context) bool {
+	s, _ := accessSampler.Load().(*accessLogSampler)
+	if s == nil {
+		return false
+	}
+	rule := s.matchRule(ctx)
+	return rule != nil && rule.Payload
+}
@@ -0,0 +1,58 @@
+package servers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func accessCtx(funcName, fromApp, requestId string, kv ...string) context.Context {
+	kv = append(kv, SERVER_INCOME_SERVER_NAME, fromApp, SERVER_INCOME_REQUEST_ID, requestId)
+	ctx := metadata.NewIncomingContext(GetRestRequestCtx(), metadata.Pairs(kv...))
+	return InitContext(ctx, funcName, nil)
+}
+
+func TestShouldAccessLog(t *testing.T) {
+	setAccessLogSetting(0, AccessLogSetting{
+		SlowMs: 100,
+		Rules: []AccessLogRule{
+			{Func: "/user.User/*", FromApp: "order", Rate: 100},
+			{Func: "/user.User/Get", Rate: 50},
+		},
+	})
+	defer setAccessLogSetting(100, AccessLogSetting{})
+
+	if !ShouldAccessLog(accessCtx("/user.User/Get", "order", "r1"), 0, false) {
+		t.Error("rule for from_app order should log")
+	}
+	if ShouldAccessLog(accessCtx("/user.User/List", "pay", "r1"), 0, false) {
+		t.Error("default rate 0 should not log")
+	}
+	if !ShouldAccessLog(accessCtx("/user.User/List", "pay", "r1"), 0, true) {
+		t.Error("failed request should log")
+	}
+	if !ShouldAccessLog(accessCtx("/user.User/List", "pay", "r1"), 200*time.Millisecond, false) {
+		t.Error("slow request should log")
+	}
+	if !ShouldAccessLog(accessCtx("/user.User/List", "pay", "r1", SERVER_INCOME_DEBUG, "1"), 0, false) {
+		t.Error("debug request should log")
+	}
+
+	// 同一个请求 id 的采样结果一致, 整体比例接近配置
+	n := 0
+	for i := 0; i < 1000; i++ {
+		id := time.Duration(i).String()
+		first := ShouldAccessLog(accessCtx("/user.User/Get", "pay", id), 0, false)
+		if first != ShouldAccessLog(accessCtx("/user.User/Get", "pay", id), 0, false) {
+			t.Fatalf("sampling of request %s is not consistent", id)
+		}
+		if first {
+			n++
+		}
+	}
+	if n < 400 || n > 600 {
+		t.Errorf("expected about 50%% sampled, got %d/1000", n)
+	}
+}
@@ -3,7 +3,6 @@ package servers
 import (
 	"context"
 	"fmt"
-	"github.com/golang/protobuf/proto"
 	"github.com/legenove/random"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
@@ -22,6 +21,9 @@ const (
 	REQUEST_TYPE_GRPC
 	REQUEST_TYPE_JRPC
 	REQUEST_TYPE_TCP
+	REQUEST_TYPE_SUB
+	REQUEST_TYPE_ASYNC
+	REQUEST_TYPE_CRON
 )
 
 const (
@@ -54,6 +56,18 @@ func GetGRPCRequestCtx(kv ...string) context.Context {
 	return GetRequestCtx(REQUEST_TYPE_GRPC, kv...)
 }
 
+func GetSubRequestCtx(kv ...string) context.Context {
+	return GetRequestCtx(REQUEST_TYPE_SUB, kv...)
+}
+
+func GetAsyncRequestCtx(kv ...string) context.Context {
+	return GetRequestCtx(REQUEST_TYPE_ASYNC, kv...)
+}
+
+func GetCronRequestCtx(kv ...string) context.Context {
+	return GetRequestCtx(REQUEST_TYPE_CRON, kv...)
+}
+
 func GetRequestCtx(st RequestType, kv ...string) context.Context {
 	newKvs := append(kv, SERVER_REQUEST_TYPE, GetServerTypeValue(st))
 	return AppendToRequestCtx(context.Background(), newKvs...)
@@ -84,6 +98,12 @@ func GetServerTypeValue(st RequestType) string {
 		return "jrpc"
 	case REQUEST_TYPE_TCP:
 		return "tcp"
+	case REQUEST_TYPE_SUB:
+		return "subscribe"
+	case REQUEST_TYPE_ASYNC:
+		return "async"
+	case REQUEST_TYPE_CRON:
+		return "cron"
 	}
 	return "grpc"
 }
@@ -127,14 +147,9 @@ func GetServerRequestFunc(ctx context.Context, raw ...map[string]string) string
 	return GetRequestValeByKey(SERVER_REQUEST_FUNC, ctx, raw...)
 }
 
+// GetServerRequestInfo 返回脱敏并截断后的请求内容, 支持 proto, 结构体, JSON 与表单
 func GetServerRequestInfo(ctx context.Context) string {
-	t := ctx.Value(serverContextRequestKey{})
-	if t != nil {
-		if r, ok := t.(proto.Message); ok {
-			return r.String()
-		}
-	}
-	return ""
+	return currentPayloadPolicy().format(ctx.Value(serverContextRequestKey{}))
 }
 
 func SetServerRequestInfo(ctx context.Context, req interface{}) context.Context {
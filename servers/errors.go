@@ -72,4 +72,6 @@ var (
 	ErrUnDefineRequest       = NewServerError("undefined_error", "10007", 400)
 	ErrRequestErr            = NewServerError("requests_error", "10008", 400)
 	ErrGetRequestHost        = NewServerError("get_request_host_error", "10009", 400)
+	ErrAdminUnauthorized     = NewServerError("admin_unauthorized", "10010", 401)
+	ErrLogLevelInvalid       = NewServerError("log_level_invalid", "10011", 400)
 )
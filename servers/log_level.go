@@ -0,0 +1,175 @@
+package servers
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/legenove/cocore"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// 管理接口使用的鉴权请求头, 值需要通过 ValidateAdminSecret 校验
+const SERVER_ADMIN_SECRET = "Nano-Admin-Secret"
+
+// ValidateAdminSecret 校验管理接口的密钥, 未配置密钥时管理接口不可用
+func ValidateAdminSecret(value string) bool {
+	if value == "" {
+		return false
+	}
+	ok := false
+	for _, secret := range Server.Secrets {
+		// 空密钥在 md5 等模式下也会生成非空值, 按未配置处理
+		if secret.Secret == "" {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(secret.getSecret()), []byte(value)) == 1 {
+			ok = true
+		}
+	}
+	return ok
+}
+
+// streamLevel 日志流的运行时级别
+type streamLevel struct {
+	level  zap.AtomicLevel
+	revert *time.Timer
+	until  time.Time
+}
+
+// levelLogger LogPool 中的 logger 与包装后的 logger
+type levelLogger struct {
+	inner   *zap.Logger
+	wrapped *zap.Logger
+}
+
+var (
+	streamLevels  = map[string]*streamLevel{}
+	levelLoggers  = map[string]levelLogger{}
+	streamLevelMu sync.Mutex
+)
+
+// defaultLogLevel 配置文件中的日志级别
+func defaultLogLevel() zapcore.Level {
+	if cocore.LogPool.Debug {
+		return zap.DebugLevel
+	}
+	return cocore.LogEnableLevel
+}
+
+// getStreamLevel 调用方需持有锁
+func getStreamLevel(name string) *streamLevel {
+	s, ok := streamLevels[name]
+	if !ok {
+		s = &streamLevel{level: zap.NewAtomicLevelAt(defaultLogLevel())}
+		streamLevels[name] = s
+	}
+	return s
+}
+
+// LogInstance 获取日志流 name 的 logger, 代替 cocore.LogPool.Instance
+// 返回的 logger 使用运行时级别, 可以通过 SetLogLevel 修改
+func LogInstance(name string) (*zap.Logger, error) {
+	inner, err := cocore.LogPool.Instance(name)
+	if err != nil {
+		return nil, err
+	}
+	streamLevelMu.Lock()
+	defer streamLevelMu.Unlock()
+	// LogPool 按天切换文件时会创建新的 logger, 需要重新包装
+	if l, ok := levelLoggers[name]; ok && l.inner == inner {
+		return l.wrapped, nil
+	}
+	level := getStreamLevel(name).level
+	wrapped := inner.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &levelCore{Core: core, level: level}
+	}))
+	levelLoggers[name] = levelLogger{inner: inner, wrapped: wrapped}
+	return wrapped, nil
+}
+
+// levelCore 使用运行时级别代替 LogPool 创建 logger 时的级别
+type levelCore struct {
+	zapcore.Core
+	level zap.AtomicLevel
+}
+
+func (c *levelCore) Enabled(l zapcore.Level) bool {
+	return c.level.Enabled(l)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), level: c.level}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.level.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// LogLevelStatus 日志流的当前级别
+type LogLevelStatus struct {
+	Stream     string `json:"stream"`
+	Level      string `json:"level"`
+	RevertAt   string `json:"revert_at,omitempty"`
+	RevertToLv string `json:"revert_to,omitempty"`
+}
+
+// GetLogLevels 返回已使用或修改过的日志流的级别
+func GetLogLevels() []LogLevelStatus {
+	streamLevelMu.Lock()
+	defer streamLevelMu.Unlock()
+	res := make([]LogLevelStatus, 0, len(streamLevels))
+	for name, s := range streamLevels {
+		status := LogLevelStatus{Stream: name, Level: s.level.Level().String()}
+		if s.revert != nil {
+			status.RevertAt = s.until.Format(time.RFC3339)
+			status.RevertToLv = defaultLogLevel().String()
+		}
+		res = append(res, status)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Stream < res[j].Stream
+	})
+	return res
+}
+
+// SetLogLevel 修改日志流的级别, revertAfter 大于 0 时到期后恢复为配置文件中的级别
+// 业务日志的日志流名为 <group>_<name>_<log_act>
+func SetLogLevel(stream, level string, revertAfter time.Duration) error {
+	if stream == "" {
+		return fmt.Errorf("log stream is required")
+	}
+	var lv zapcore.Level
+	if err := lv.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level: %s", level)
+	}
+	streamLevelMu.Lock()
+	defer streamLevelMu.Unlock()
+	s := getStreamLevel(stream)
+	if s.revert != nil {
+		s.revert.Stop()
+		s.revert = nil
+	}
+	s.level.SetLevel(lv)
+	if revertAfter > 0 {
+		s.until = time.Now().Add(revertAfter)
+		var timer *time.Timer
+		timer = time.AfterFunc(revertAfter, func() {
+			streamLevelMu.Lock()
+			defer streamLevelMu.Unlock()
+			// 期间被再次修改时由新的定时器处理
+			if s.revert == timer {
+				s.level.SetLevel(defaultLogLevel())
+				s.revert = nil
+			}
+		})
+		s.revert = timer
+	}
+	return nil
+}
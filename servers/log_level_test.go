@@ -0,0 +1,47 @@
+package servers
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLevelCoreOverridesInnerLevel(t *testing.T) {
+	inner, logs := observer.New(zapcore.ErrorLevel)
+	level := zap.NewAtomicLevelAt(zapcore.DebugLevel)
+	logger := zap.New(&levelCore{Core: inner, level: level}).With(zap.String("k", "v"))
+	logger.Debug("debug")
+	level.SetLevel(zapcore.WarnLevel)
+	logger.Info("info")
+	if logs.Len() != 1 || logs.All()[0].Message != "debug" {
+		t.Errorf("unexpected entries: %v", logs.All())
+	}
+}
+
+func TestSetLogLevelRevert(t *testing.T) {
+	if err := SetLogLevel("test_stream", "verbose", 0); err == nil {
+		t.Error("invalid level should return error")
+	}
+	if err := SetLogLevel("test_stream", "debug", 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if got := findLogLevel("test_stream"); got.Level != "debug" || got.RevertAt == "" {
+		t.Errorf("unexpected status: %+v", got)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if got := findLogLevel("test_stream"); got.Level != defaultLogLevel().String() || got.RevertAt != "" {
+		t.Errorf("level should be reverted: %+v", got)
+	}
+}
+
+func findLogLevel(stream string) LogLevelStatus {
+	for _, l := range GetLogLevels() {
+		if l.Stream == stream {
+			return l
+		}
+	}
+	return LogLevelStatus{}
+}
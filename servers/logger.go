@@ -0,0 +1,148 @@
+package servers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/legenove/utils"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// 业务日志的 logger, 按 log_act 缓存带上固定字段的 logger
+// LogPool 按天切换文件后 LogInstance 返回新的 logger, 缓存随之失效
+var eventLoggers sync.Map
+
+type cachedEventLogger struct {
+	base   *zap.Logger
+	logger *zap.Logger
+}
+
+func resetEventLoggers() {
+	eventLoggers.Range(func(key, _ interface{}) bool {
+		eventLoggers.Delete(key)
+		return true
+	})
+}
+
+// eventLogger 返回业务日志流 <group>_<name>_<log_act> 的 logger, 已带上 log_type 与 event
+func eventLogger(logAct string) (*zap.Logger, error) {
+	if !utils.CheckNormalKey(logAct) {
+		return nil, fmt.Errorf("log_act:%s, only support a-zA-Z0-9 '-_' and  must startwith a-zA-Z", logAct)
+	}
+	eventString := getEventString(logAct)
+	base, err := LogInstance(eventString)
+	if err != nil {
+		return nil, err
+	}
+	if l, ok := eventLoggers.Load(logAct); ok && l.(cachedEventLogger).base == base {
+		return l.(cachedEventLogger).logger, nil
+	}
+	logger := base.With(
+		zap.String("log_type", LOG_TYPE_OTHER),
+		zap.String("event", eventString),
+	)
+	eventLoggers.Store(logAct, cachedEventLogger{base: base, logger: logger})
+	return logger, nil
+}
+
+// RequestLogger 绑定了请求信息的业务日志入口
+//
+//	log := servers.Logger(ctx)
+//	log.Event("order_pay").Info("paid", servers.LogFields{}.String("orderId", id).Int64("amount", amount)...)
+type RequestLogger struct {
+	fields []zapcore.Field
+}
+
+// Logger 返回绑定了 AddRequestLog 中请求字段的 logger, 同一个请求中可以复用
+func Logger(ctx context.Context) *RequestLogger {
+	return &RequestLogger{fields: requestFields(ctx)}
+}
+
+// Event 返回写入 log_act 业务日志流的 logger, log_act 不合法或获取失败时不输出日志
+func (r *RequestLogger) Event(logAct string) *EventLogger {
+	logger, err := eventLogger(logAct)
+	if err != nil {
+		return &EventLogger{logger: zap.NewNop(), logAct: logAct}
+	}
+	return &EventLogger{logger: logger.With(r.fields...), logAct: logAct}
+}
+
+// EventLogger 业务日志, 字段名自动加上 log_act 前缀并放在 properties 下, 与 LogKV 的输出一致
+type EventLogger struct {
+	logger *zap.Logger
+	logAct string
+}
+
+func (e *EventLogger) Debug(msg string, fields ...zapcore.Field) {
+	e.logger.Debug(msg, e.prefix(fields)...)
+}
+
+func (e *EventLogger) Info(msg string, fields ...zapcore.Field) {
+	e.logger.Info(msg, e.prefix(fields)...)
+}
+
+func (e *EventLogger) Warn(msg string, fields ...zapcore.Field) {
+	e.logger.Warn(msg, e.prefix(fields)...)
+}
+
+func (e *EventLogger) Error(msg string, fields ...zapcore.Field) {
+	e.logger.Error(msg, e.prefix(fields)...)
+	e.logger.Sync()
+}
+
+func (e *EventLogger) prefix(fields []zapcore.Field) []zapcore.Field {
+	res := make([]zapcore.Field, 0, len(fields)+2)
+	res = append(res, zap.Namespace("properties"))
+	for _, f := range fields {
+		if f.Key != "log_act" {
+			f.Key = utils.ConcatenateStrings(e.logAct, "_", f.Key)
+		}
+		res = append(res, f)
+	}
+	return append(res, zap.String("log_act", e.logAct))
+}
+
+// LogFields 业务日志字段构造器
+type LogFields []zapcore.Field
+
+func (f LogFields) String(key, val string) LogFields {
+	return append(f, zap.String(key, val))
+}
+
+func (f LogFields) Int(key string, val int) LogFields {
+	return append(f, zap.Int(key, val))
+}
+
+func (f LogFields) Int64(key string, val int64) LogFields {
+	return append(f, zap.Int64(key, val))
+}
+
+func (f LogFields) Float64(key string, val float64) LogFields {
+	return append(f, zap.Float64(key, val))
+}
+
+func (f LogFields) Bool(key string, val bool) LogFields {
+	return append(f, zap.Bool(key, val))
+}
+
+func (f LogFields) Duration(key string, val time.Duration) LogFields {
+	return append(f, zap.Duration(key, val))
+}
+
+func (f LogFields) Time(key string, val time.Time) LogFields {
+	return append(f, zap.Time(key, val))
+}
+
+func (f LogFields) Err(err error) LogFields {
+	if err == nil {
+		return f
+	}
+	return append(f, zap.String("error", err.Error()))
+}
+
+func (f LogFields) Any(key string, val interface{}) LogFields {
+	return append(f, zap.Reflect(key, val))
+}
@@ -0,0 +1,50 @@
+package servers
+
+import (
+	"os"
+	"testing"
+
+	"github.com/legenove/cocore"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestEventLoggerPrefix(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	e := &EventLogger{logger: zap.New(core), logAct: "order_pay"}
+	e.Info("paid", LogFields{}.String("orderId", "o1").Int64("amount", 100)...)
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+	ctx := entries[0].ContextMap()
+	props, ok := ctx["properties"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("fields should be under properties: %v", ctx)
+	}
+	if props["order_pay_orderId"] != "o1" || props["order_pay_amount"] != int64(100) || props["log_act"] != "order_pay" {
+		t.Errorf("unexpected properties: %v", props)
+	}
+}
+
+// LogPool 切换文件后, 缓存中旧 logger 对应的业务日志 logger 不再使用
+func TestEventLoggerFollowsLogInstance(t *testing.T) {
+	if cocore.App == nil {
+		cocore.InitApp(true, "", os.TempDir(), "")
+	}
+	first, err := eventLogger("cache_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := eventLogger("cache_test"); again != first {
+		t.Error("logger should be cached while LogInstance is unchanged")
+	}
+	// 模拟缓存来自切换前的 logger
+	stale := zap.NewNop()
+	eventLoggers.Store("cache_test", cachedEventLogger{base: zap.NewNop(), logger: stale})
+	if next, err := eventLogger("cache_test"); err != nil || next == stale {
+		t.Error("stale cached logger should be replaced")
+	}
+}
@@ -24,7 +24,7 @@ var (
 	LogDirSub     string
 	LogDirOther   string
 
-	// 开放access日志比例，默认全部开放
+	// 开放access日志比例，默认全部开放, 没有匹配的采样规则时使用
 	OpenAccessLog int
 )
 
@@ -34,6 +34,8 @@ var (
 	LogEventMysql   string
 	LogEventRedis   string
 	LogEventRequest string
+	LogEventSub     string
+	LogEventAsync   string
 )
 
 const (
@@ -62,6 +64,7 @@ func InitServerLog() {
 	} else {
 		OpenAccessLog = i
 	}
+	loadAccessLogSetting()
 	LogDirError = cocore.App.GetStringConfig("ERROR_LOG_NAME", LOG_TYPE_APP_ERROR)
 	LogDirAccess = cocore.App.GetStringConfig("ACCESS_LOG_NAME", LOG_TYPE_APP_ACCESS)
 	LogDirWarn = cocore.App.GetStringConfig("WARN_LOG_NAME", LOG_TYPE_APP_WARN)
@@ -76,6 +79,10 @@ func InitServerLog() {
 	LogEventMysql = utils.ConcatenateStrings(LogDirOther, "_", LOG_TYPE_MYSQL)
 	LogEventRedis = utils.ConcatenateStrings(LogDirOther, "_", LOG_TYPE_REDIS)
 	LogEventRequest = utils.ConcatenateStrings(LogDirOther, "_", LOG_TYPE_REQUEST)
+	LogEventSub = utils.ConcatenateStrings(LogDirOther, "_", LOG_TYPE_SUB)
+	LogEventAsync = utils.ConcatenateStrings(LogDirOther, "_", LOG_TYPE_ASYNC)
+	// 日志配置变化后重新获取业务日志 logger
+	resetEventLoggers()
 }
 
 func AccessLog(logger *zap.Logger, ctx context.Context, duration time.Duration) {
@@ -84,7 +91,14 @@ func AccessLog(logger *zap.Logger, ctx context.Context, duration time.Duration)
 		md = metadata.MD{}
 	}
 	raw := GetRequestRaw(ctx)
-	logger.Info("access",
+	var payload []zapcore.Field
+	if CapturePayload(ctx) {
+		payload = []zapcore.Field{
+			zap.String("query", GetServerRequestInfo(ctx)),
+			zap.String("response", GetServerResponseInfo(ctx)),
+		}
+	}
+	logger.Info("access", append([]zapcore.Field{
 		zap.String("log_type", LOG_TYPE_APP_ACCESS),
 		zap.String("event", LogEventAccess),
 		zap.String("logServer", Server.GetServerName()),
@@ -96,20 +110,19 @@ func AccessLog(logger *zap.Logger, ctx context.Context, duration time.Duration)
 		zap.String("requestId", GetRequestId(ctx, md)),
 		zap.String("clientIp", GetContextIP(ctx, md)),
 		zap.Namespace("properties"),
-		// TODO 增加query
-		//zap.String("query", query),
 		zap.String("user-agent", GetUserAgent(ctx, md)),
 		zap.Duration("time", duration),
-	)
+	}, payload...)...)
 }
 
-func ErrorLog(logger *zap.Logger, ctx context.Context, error_code, reason interface{}, duration time.Duration) {
+// ErrorLog fields 追加在 properties 中, 如 panic 的调用栈
+func ErrorLog(logger *zap.Logger, ctx context.Context, error_code, reason interface{}, duration time.Duration, fields ...zapcore.Field) {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
 		md = metadata.MD{}
 	}
 	raw := GetRequestRaw(ctx)
-	logger.Error("error",
+	logger.Error("error", append([]zapcore.Field{
 		zap.String("log_type", LOG_TYPE_APP_ERROR),
 		zap.String("event", LogEventError),
 		zap.String("logServer", Server.GetServerName()),
@@ -125,16 +138,18 @@ func ErrorLog(logger *zap.Logger, ctx context.Context, error_code, reason interf
 		zap.String("query", GetServerRequestInfo(ctx)),
 		zap.String("user-agent", GetUserAgent(ctx, md)),
 		zap.Duration("time", duration),
-		zap.Reflect("reason", reason))
+		zap.Reflect("reason", reason),
+	}, fields...)...)
 }
 
-func WarnLog(logger *zap.Logger, ctx context.Context, error_code, reason interface{}, duration time.Duration) {
+// WarnLog fields 追加在 properties 中, 如 panic 的调用栈
+func WarnLog(logger *zap.Logger, ctx context.Context, error_code, reason interface{}, duration time.Duration, fields ...zapcore.Field) {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
 		md = metadata.MD{}
 	}
 	raw := GetRequestRaw(ctx)
-	logger.Warn("warning",
+	logger.Warn("warning", append([]zapcore.Field{
 		zap.String("log_type", LOG_TYPE_APP_WARN),
 		zap.String("event", LogEventError),
 		zap.String("logServer", Server.GetServerName()),
@@ -150,17 +165,23 @@ func WarnLog(logger *zap.Logger, ctx context.Context, error_code, reason interfa
 		zap.String("query", GetServerRequestInfo(ctx)),
 		zap.String("user-agent", GetUserAgent(ctx, md)),
 		zap.Duration("time", duration),
-		zap.Reflect("reason", reason))
+		zap.Reflect("reason", reason),
+	}, fields...)...)
 }
 
 func AddRequestLog(logger *zap.Logger, ctx context.Context) *zap.Logger {
+	return logger.With(requestFields(ctx)...)
+}
+
+// requestFields 请求相关的公共日志字段
+func requestFields(ctx context.Context) []zapcore.Field {
 	if ctx != nil {
 		md, ok := metadata.FromIncomingContext(ctx)
 		if !ok {
 			md = metadata.MD{}
 		}
 		raw := GetRequestRaw(ctx)
-		return logger.With([]zapcore.Field{
+		return []zapcore.Field{
 			zap.String("logServer", Server.GetServerName()),
 			zap.String("logServerGroup", Server.GetServerGroup()),
 			zap.String("requestType", GetServerRequestType(ctx, raw)),
@@ -169,9 +190,9 @@ func AddRequestLog(logger *zap.Logger, ctx context.Context) *zap.Logger {
 			zap.String("fromProject", GetServerGroup(ctx, md)),
 			zap.String("requestId", GetRequestId(ctx)),
 			zap.String("clientIp", GetContextIP(ctx, md)),
-		}...)
+		}
 	} else {
-		return logger.With([]zapcore.Field{
+		return []zapcore.Field{
 			zap.String("logServer", Server.GetServerName()),
 			zap.String("logServerGroup", Server.GetServerGroup()),
 			zap.String("requestType", ""),
@@ -180,7 +201,7 @@ func AddRequestLog(logger *zap.Logger, ctx context.Context) *zap.Logger {
 			zap.String("fromProject", ""),
 			zap.String("requestId", ""),
 			zap.String("clientIp", ""),
-		}...)
+		}
 	}
 }
 
@@ -248,18 +269,10 @@ func getEventString(event string) string {
  *    servers.LogKV( core.LOG_LEVEL_INFO,"message", "finan_add", ctx, key1, val1, key2, val2, key3, val3, ...)
  */
 func LogKV(level, message string, logAct string, ctx context.Context, options ...interface{}) error {
-	if !utils.CheckNormalKey(logAct) {
-		return fmt.Errorf("log_act:%s, only support a-zA-Z0-9 '-_' and  must startwith a-zA-Z", logAct)
-	}
-	eventString := getEventString(logAct)
-	var err error
-	logger, err := cocore.LogPool.Instance(eventString)
+	logger, err := eventLogger(logAct)
 	if err != nil {
 		return err
 	}
-	logger = logger.With([]zapcore.Field{
-		zap.String("log_type", LOG_TYPE_OTHER),
-		zap.String("event", eventString)}...)
 	logger = AddRequestLog(logger, ctx)
 	options = append(options, "log_act", logAct)
 	var fields []zapcore.Field
@@ -0,0 +1,317 @@
+package servers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/proto"
+	jsoniter "github.com/json-iterator/go"
+	"google.golang.org/protobuf/encoding/protojson"
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	redactedValue          = "***"
+	defaultPayloadMaxBytes = 2048
+	maxPayloadDepth        = 16
+)
+
+// 默认脱敏的字段名, 比较时忽略大小写与 '_' '-'
+var defaultRedactFields = []string{
+	"password", "passwd", "pwd", "secret", "token", "access_token", "refresh_token",
+	"authorization", "cookie", "credential", "id_card", "bank_card",
+}
+
+var (
+	redactOptions  []protoreflect.ExtensionType
+	redactOptionMu sync.RWMutex
+)
+
+// RegisterRedactOption 注册 bool 类型的 proto 字段选项, 选项为 true 的字段输出日志时脱敏
+//
+//	extend google.protobuf.FieldOptions { bool sensitive = 50001; }
+//	string phone = 1 [(sensitive) = true];
+func RegisterRedactOption(xt protoreflect.ExtensionType) {
+	redactOptionMu.Lock()
+	defer redactOptionMu.Unlock()
+	redactOptions = append(redactOptions, xt)
+}
+
+// payloadPolicy 请求与返回内容的截断与脱敏策略
+type payloadPolicy struct {
+	maxBytes int
+	fields   map[string]struct{}
+}
+
+func newPayloadPolicy(maxBytes int, fields []string) *payloadPolicy {
+	if maxBytes <= 0 {
+		maxBytes = defaultPayloadMaxBytes
+	}
+	p := &payloadPolicy{maxBytes: maxBytes, fields: map[string]struct{}{}}
+	for _, f := range append(defaultRedactFields, fields...) {
+		p.fields[normalizeFieldName(f)] = struct{}{}
+	}
+	return p
+}
+
+func normalizeFieldName(name string) string {
+	name = strings.ToLower(name)
+	name = strings.Replace(name, "_", "", -1)
+	return strings.Replace(name, "-", "", -1)
+}
+
+func (p *payloadPolicy) sensitive(name string) bool {
+	_, ok := p.fields[normalizeFieldName(name)]
+	return ok
+}
+
+// format 将 proto, 结构体, JSON 或表单内容脱敏后序列化为 JSON, 超过长度时截断
+func (p *payloadPolicy) format(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	var data []byte
+	if m, ok := v.(proto.Message); ok {
+		data = p.protoJSON(m)
+	} else {
+		var err error
+		data, err = payloadJSON.Marshal(p.redact(reflect.ValueOf(v), 0))
+		if err != nil {
+			return ""
+		}
+	}
+	return p.truncate(data)
+}
+
+func (p *payloadPolicy) truncate(data []byte) string {
+	if len(data) <= p.maxBytes {
+		return string(data)
+	}
+	n := p.maxBytes
+	for n > 0 && !utf8.RuneStart(data[n]) {
+		n--
+	}
+	return string(data[:n]) + "...(truncated)"
+}
+
+func (p *payloadPolicy) protoJSON(m proto.Message) []byte {
+	if v := reflect.ValueOf(m); v.Kind() == reflect.Ptr && v.IsNil() {
+		return []byte("null")
+	}
+	msg := protov2.Clone(proto.MessageV2(m))
+	p.redactProto(msg.ProtoReflect(), 0)
+	data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(msg)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+func (p *payloadPolicy) redactProto(m protoreflect.Message, depth int) {
+	if depth > maxPayloadDepth {
+		return
+	}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if p.sensitive(string(fd.Name())) || hasRedactOption(fd) {
+			if fd.Kind() == protoreflect.StringKind && !fd.IsList() && !fd.IsMap() {
+				m.Set(fd, protoreflect.ValueOfString(redactedValue))
+			} else {
+				m.Clear(fd)
+			}
+			return true
+		}
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			l := v.List()
+			for i := 0; i < l.Len(); i++ {
+				p.redactProto(l.Get(i).Message(), depth+1)
+			}
+		case fd.IsMap():
+			mp := v.Map()
+			mp.Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+				if fd.MapKey().Kind() == protoreflect.StringKind && p.sensitive(k.String()) {
+					if fd.MapValue().Kind() == protoreflect.StringKind {
+						mp.Set(k, protoreflect.ValueOfString(redactedValue))
+					} else {
+						mp.Clear(k)
+					}
+				} else if fd.MapValue().Message() != nil {
+					p.redactProto(mv.Message(), depth+1)
+				}
+				return true
+			})
+		case !fd.IsList() && !fd.IsMap() && fd.Message() != nil:
+			p.redactProto(v.Message(), depth+1)
+		}
+		return true
+	})
+}
+
+func hasRedactOption(fd protoreflect.FieldDescriptor) bool {
+	opts := fd.Options()
+	if opts == nil {
+		return false
+	}
+	redactOptionMu.RLock()
+	defer redactOptionMu.RUnlock()
+	for _, xt := range redactOptions {
+		if protov2.HasExtension(opts, xt) {
+			if b, ok := protov2.GetExtension(opts, xt).(bool); ok && b {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// map 按 key 排序输出, 方便对比日志
+var payloadJSON = jsoniter.ConfigCompatibleWithStandardLibrary
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// redact 将任意值转换为脱敏后可以序列化的值
+// 结构体字段名使用 json tag, 带有 log:"redact" 的字段脱敏, log:"-" 的字段不输出
+func (p *payloadPolicy) redact(v reflect.Value, depth int) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if depth > maxPayloadDepth {
+		return "..."
+	}
+	if v.CanInterface() {
+		if m, ok := v.Interface().(proto.Message); ok {
+			return json.RawMessage(p.protoJSON(m))
+		}
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return p.redact(v.Elem(), depth)
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return p.redactBytes(v.Bytes(), depth)
+		}
+		fallthrough
+	case reflect.Array:
+		res := make([]interface{}, v.Len())
+		for i := range res {
+			res[i] = p.redact(v.Index(i), depth+1)
+		}
+		return res
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		// 非字符串的 key 按 fmt 格式转换, 与 JSON 输出一致
+		res := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := fmt.Sprint(iter.Key())
+			if p.sensitive(key) {
+				res[key] = redactedValue
+			} else {
+				res[key] = p.redact(iter.Value(), depth+1)
+			}
+		}
+		return res
+	case reflect.Struct:
+		if v.Type().Implements(jsonMarshalerType) {
+			return v.Interface()
+		}
+		return p.redactStruct(v, depth)
+	}
+	if v.CanInterface() {
+		return v.Interface()
+	}
+	return nil
+}
+
+func (p *payloadPolicy) redactStruct(v reflect.Value, depth int) map[string]interface{} {
+	t := v.Type()
+	res := make(map[string]interface{}, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		logTag := f.Tag.Get("log")
+		if (f.PkgPath != "" && !f.Anonymous) || logTag == "-" {
+			continue
+		}
+		name := f.Name
+		if tag := f.Tag.Get("json"); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			if n := strings.Split(tag, ",")[0]; n != "" {
+				name = n
+			} else if f.Anonymous {
+				name = ""
+			}
+		} else if f.Anonymous {
+			name = ""
+		}
+		if name == "" {
+			// 嵌入的结构体字段展开到外层
+			if sub, ok := p.redact(v.Field(i), depth+1).(map[string]interface{}); ok {
+				for k, sv := range sub {
+					res[k] = sv
+				}
+			}
+			continue
+		}
+		if logTag == "redact" || p.sensitive(name) {
+			res[name] = redactedValue
+			continue
+		}
+		res[name] = p.redact(v.Field(i), depth+1)
+	}
+	return res
+}
+
+// redactBytes 请求体按 JSON 或表单解析后脱敏, 无法解析时原样输出
+func (p *payloadPolicy) redactBytes(data []byte, depth int) interface{} {
+	var body interface{}
+	if err := jsoniter.Unmarshal(data, &body); err == nil {
+		return p.redact(reflect.ValueOf(body), depth+1)
+	}
+	if form, err := url.ParseQuery(string(data)); err == nil && strings.Contains(string(data), "=") {
+		return p.redact(reflect.ValueOf(form), depth+1)
+	}
+	return string(data)
+}
+
+// PayloadMaxBytes 请求与返回内容的最大长度
+func PayloadMaxBytes() int {
+	return currentPayloadPolicy().maxBytes
+}
+
+func currentPayloadPolicy() *payloadPolicy {
+	if s, _ := accessSampler.Load().(*accessLogSampler); s != nil && s.payload != nil {
+		return s.payload
+	}
+	return defaultPayloadPolicy
+}
+
+var defaultPayloadPolicy = newPayloadPolicy(0, nil)
+
+type serverContextResponseKey struct{}
+
+// SetServerResponseInfo 保存返回内容, 路由开启 payload 时写入 access 日志
+func SetServerResponseInfo(ctx context.Context, resp interface{}) context.Context {
+	return context.WithValue(ctx, serverContextResponseKey{}, resp)
+}
+
+// GetServerResponseInfo 返回脱敏并截断后的返回内容
+func GetServerResponseInfo(ctx context.Context) string {
+	return currentPayloadPolicy().format(ctx.Value(serverContextResponseKey{}))
+}
@@ -0,0 +1,60 @@
+package servers
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	structpb "github.com/golang/protobuf/ptypes/struct"
+)
+
+type payloadUser struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	Phone    string `json:"phone" log:"redact"`
+	Internal string `log:"-"`
+	payloadMeta
+}
+
+type payloadMeta struct {
+	AccessToken string
+	Source      string `json:"source"`
+}
+
+func TestPayloadPolicyFormat(t *testing.T) {
+	p := newPayloadPolicy(0, []string{"phone_no"})
+	cases := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{"struct", &payloadUser{Name: "n", Password: "p", Phone: "1", Internal: "i", payloadMeta: payloadMeta{AccessToken: "t", Source: "web"}},
+			`{"AccessToken":"***","name":"n","password":"***","phone":"***","source":"web"}`},
+		{"json", []byte(`{"user":{"Phone-No":"1","token":"t"},"page":1}`), `{"page":1,"user":{"Phone-No":"***","token":"***"}}`},
+		{"form", url.Values{"name": {"n"}, "pwd": {"p"}}, `{"name":["n"],"pwd":"***"}`},
+		{"form body", []byte("name=n&password=p"), `{"name":["n"],"password":"***"}`},
+		{"text", []byte("plain text"), `"plain text"`},
+		{"int key", map[int]payloadUser{1: {Name: "n", Password: "p"}}, `{"1":{"AccessToken":"***","name":"n","password":"***","phone":"***","source":""}}`},
+		{"proto", &structpb.Struct{Fields: map[string]*structpb.Value{
+			"name":     {Kind: &structpb.Value_StringValue{StringValue: "n"}},
+			"password": {Kind: &structpb.Value_StringValue{StringValue: "p"}},
+		}}, `{"name":"n"}`},
+	}
+	for _, c := range cases {
+		got := p.format(c.v)
+		if c.name == "proto" {
+			// protojson 输出的空格不固定
+			got = strings.Replace(got, " ", "", -1)
+		}
+		if got != c.want {
+			t.Errorf("%s: got %s, want %s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestPayloadPolicyTruncate(t *testing.T) {
+	p := newPayloadPolicy(8, nil)
+	if got := p.format([]byte(`"0123456789"`)); got != `"0123456...(truncated)` {
+		t.Errorf("unexpected truncate result: %s", got)
+	}
+}
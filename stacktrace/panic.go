@@ -0,0 +1,32 @@
+package stacktrace
+
+import (
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// PanicError 恢复的 panic, 保留 panic 处的调用栈, 写日志时通过 ErrorFields 输出
+type PanicError struct {
+	Value interface{}
+	Stack Stack
+}
+
+// NewPanicError 在 recover 所在的 defer 函数中调用
+func NewPanicError(r interface{}) *PanicError {
+	return &PanicError{Value: r, Stack: Capture(2)}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("[Recovery] panic recovered: %v", e.Value)
+}
+
+// ErrorFields err 为恢复的 panic 时返回调用栈字段, 否则返回 nil
+func ErrorFields(err error) []zapcore.Field {
+	var pe *PanicError
+	if errors.As(err, &pe) {
+		return pe.Stack.Fields()
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+// 结构化的调用栈, 各 server 的 panic 恢复共用
+// 跳过 runtime 与 sdk 自身的栈帧, 错误日志可以按 top_frame 聚合
+package stacktrace
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"runtime"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	// MaxDepth 最多记录的栈帧数量
+	MaxDepth = 32
+	// SkipPrefixes 以这些前缀开头的函数不记录
+	SkipPrefixes = []string{"runtime.", "github.com/legenove/nano-server-sdk/"}
+)
+
+// Frame 一个栈帧
+type Frame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Source   string `json:"source,omitempty"`
+}
+
+func (f Frame) String() string {
+	return fmt.Sprintf("%s %s:%d", f.Function, f.File, f.Line)
+}
+
+func (f Frame) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("function", f.Function)
+	enc.AddString("file", f.File)
+	enc.AddInt("line", f.Line)
+	if f.Source != "" {
+		enc.AddString("source", f.Source)
+	}
+	return nil
+}
+
+// Stack 调用栈, 从 panic 或调用处开始
+type Stack []Frame
+
+// Capture 获取当前调用栈, skip 为需要跳过的调用层数, 0 为调用 Capture 的函数
+// 所有栈帧都被跳过时保留原始调用栈
+func Capture(skip int) Stack {
+	pcs := make([]uintptr, MaxDepth+64)
+	n := runtime.Callers(skip+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var all, res Stack
+	for {
+		f, more := frames.Next()
+		frame := Frame{Function: f.Function, File: f.File, Line: f.Line}
+		if len(all) < MaxDepth {
+			all = append(all, frame)
+		}
+		if !skipped(f.Function) && len(res) < MaxDepth {
+			res = append(res, frame)
+		}
+		if !more {
+			break
+		}
+	}
+	if len(res) == 0 {
+		res = all
+	}
+	for i := range res {
+		res[i].Source = source(res[i].File, res[i].Line)
+	}
+	return res
+}
+
+func skipped(function string) bool {
+	for _, p := range SkipPrefixes {
+		if strings.HasPrefix(function, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Top 第一个栈帧, 用于错误聚合
+func (s Stack) Top() Frame {
+	if len(s) == 0 {
+		return Frame{}
+	}
+	return s[0]
+}
+
+// String 与之前 stack() 一致的文本格式
+func (s Stack) String() string {
+	buf := new(bytes.Buffer)
+	for _, f := range s {
+		fmt.Fprintf(buf, "%s:%d\n\t%s: %s\n", f.File, f.Line, f.Function, f.Source)
+	}
+	return buf.String()
+}
+
+func (s Stack) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, f := range s {
+		if err := enc.AppendObject(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Fields 写入错误日志的字段
+func (s Stack) Fields() []zapcore.Field {
+	return []zapcore.Field{
+		zap.String("top_frame", s.Top().String()),
+		zap.Array("stack", s),
+	}
+}
+
+const maxCachedFiles = 256
+
+var (
+	sourceCache   = map[string][][]byte{}
+	sourceCacheMu sync.Mutex
+)
+
+// source 返回文件第 line 行的内容, 文件内容缓存避免每次 panic 读取磁盘
+func source(file string, line int) string {
+	sourceCacheMu.Lock()
+	lines, ok := sourceCache[file]
+	sourceCacheMu.Unlock()
+	if !ok {
+		// 读取失败时同样缓存, 线上环境通常没有源码
+		if data, err := ioutil.ReadFile(file); err == nil {
+			lines = bytes.Split(data, []byte{'\n'})
+		}
+		sourceCacheMu.Lock()
+		if len(sourceCache) >= maxCachedFiles {
+			sourceCache = map[string][][]byte{}
+		}
+		sourceCache[file] = lines
+		sourceCacheMu.Unlock()
+	}
+	line-- // in stack trace, lines are 1-indexed but our array is 0-indexed
+	if line < 0 || line >= len(lines) {
+		return ""
+	}
+	return string(bytes.TrimSpace(lines[line]))
+}
@@ -0,0 +1,79 @@
+package stacktrace
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func panicHere() {
+	var m map[string]int
+	m["a"] = 1
+}
+
+func recoverStack() (s Stack) {
+	defer func() {
+		recover()
+		s = Capture(1)
+	}()
+	panicHere()
+	return nil
+}
+
+func TestCaptureSkipsRuntimeFrames(t *testing.T) {
+	defer func(p []string) { SkipPrefixes = p }(SkipPrefixes)
+	SkipPrefixes = []string{"runtime."}
+
+	s := recoverStack()
+	top := s.Top()
+	if !strings.HasSuffix(top.Function, ".panicHere") {
+		t.Fatalf("top frame should be the panic site, got %s", top)
+	}
+	if top.Source != `m["a"] = 1` {
+		t.Errorf("unexpected source: %q", top.Source)
+	}
+	for _, f := range s {
+		if strings.HasPrefix(f.Function, "runtime.") {
+			t.Errorf("runtime frame not skipped: %s", f)
+		}
+	}
+}
+
+func TestCaptureSkipsSdkFrames(t *testing.T) {
+	defer func(n int) { MaxDepth = n }(MaxDepth)
+	MaxDepth = 2
+	s := Capture(0)
+	if len(s) == 0 || len(s) > 2 {
+		t.Fatalf("unexpected stack depth: %v", s)
+	}
+	for _, f := range s {
+		if strings.HasPrefix(f.Function, "github.com/legenove/nano-server-sdk/") {
+			t.Errorf("sdk frame not skipped: %s", f)
+		}
+	}
+}
+
+func TestPanicError(t *testing.T) {
+	defer func(p []string) { SkipPrefixes = p }(SkipPrefixes)
+	SkipPrefixes = []string{"runtime."}
+
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = NewPanicError(r)
+			}
+		}()
+		panicHere()
+		return nil
+	}()
+	if !strings.HasPrefix(err.Error(), "[Recovery] panic recovered: ") {
+		t.Errorf("unexpected error: %s", err)
+	}
+	fields := ErrorFields(err)
+	if len(fields) != 2 || !strings.Contains(fields[0].String, ".panicHere") {
+		t.Errorf("top frame should be the panic site, got %v", fields)
+	}
+	if ErrorFields(errors.New("failed")) != nil {
+		t.Error("plain errors should not have stack fields")
+	}
+}
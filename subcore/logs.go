@@ -0,0 +1,39 @@
+package subcore
+
+import (
+	"context"
+	"time"
+
+	"github.com/legenove/nano-server-sdk/servers"
+	"go.uber.org/zap"
+)
+
+// subLog 写入订阅日志, 失败与死信使用 error 级别
+func subLog(ctx context.Context, msg *Message, status, reason string, duration time.Duration, extra ...zap.Field) {
+	logger, err := servers.LogInstance(servers.LogDirSub)
+	if err != nil {
+		return
+	}
+	logger = servers.AddRequestLog(logger, ctx).With(
+		zap.String("log_type", servers.LOG_TYPE_SUB),
+		zap.String("event", servers.LogEventSub),
+	)
+	fields := []zap.Field{zap.Namespace("properties"), zap.String("status", status)}
+	if msg != nil {
+		fields = append(fields,
+			zap.String("stream", msg.Stream),
+			zap.String("messageId", msg.ID),
+			zap.Int64("deliveries", msg.Deliveries),
+		)
+	}
+	fields = append(fields, zap.Duration("time", duration))
+	if reason != "" {
+		fields = append(fields, zap.String("reason", reason))
+	}
+	fields = append(fields, extra...)
+	if status == "success" {
+		logger.Info("subscribe", fields...)
+	} else {
+		logger.Error("subscribe", fields...)
+	}
+}
@@ -0,0 +1,29 @@
+package subcore
+
+import (
+	"context"
+
+	"github.com/go-redis/redis"
+	"github.com/legenove/nano-server-sdk/redis_client"
+	"github.com/legenove/nano-server-sdk/servers"
+)
+
+// Publish 写入消息, 并带上当前请求 id 与服务名, 消费端用于串联日志
+func Publish(ctx context.Context, routerName, stream string, values map[string]interface{}, maxLen ...int64) (string, error) {
+	client, err := redis_client.GetRedisClientWithContext(ctx, routerName)
+	if err != nil {
+		return "", err
+	}
+	v := make(map[string]interface{}, len(values)+3)
+	for k, val := range values {
+		v[k] = val
+	}
+	v[servers.SERVER_INCOME_REQUEST_ID] = servers.GetRequestId(ctx)
+	v[servers.SERVER_INCOME_SERVER_NAME] = servers.Server.GetServerName()
+	v[servers.SERVER_INCOME_SERVER_GROUP] = servers.Server.GetServerGroup()
+	args := &redis.XAddArgs{Stream: stream, Values: v}
+	if len(maxLen) > 0 {
+		args.MaxLenApprox = maxLen[0]
+	}
+	return client.XAdd(args).Result()
+}
@@ -0,0 +1,52 @@
+// 订阅任务, 基于 redis stream 消费组
+package subcore
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-redis/redis"
+)
+
+// Message stream 中的一条消息
+type Message struct {
+	Stream string
+	ID     string
+	Values map[string]interface{}
+	// 已投递次数, 首次投递为 1
+	Deliveries int64
+}
+
+type Handler func(ctx context.Context, msg *Message) error
+
+var handlerMapper = map[string]Handler{}
+var mu sync.Mutex
+
+// RegisterHandler 注册 stream 的处理函数
+func RegisterHandler(stream string, h Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	handlerMapper[stream] = h
+}
+
+func getHandlers() map[string]Handler {
+	mu.Lock()
+	defer mu.Unlock()
+	res := make(map[string]Handler, len(handlerMapper))
+	for k, v := range handlerMapper {
+		res[k] = v
+	}
+	return res
+}
+
+func newMessage(stream string, m redis.XMessage, deliveries int64) *Message {
+	return &Message{Stream: stream, ID: m.ID, Values: m.Values, Deliveries: deliveries}
+}
+
+// GetString 读取字符串字段
+func (m *Message) GetString(key string) string {
+	if v, ok := m.Values[key].(string); ok {
+		return v
+	}
+	return ""
+}
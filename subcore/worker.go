@@ -0,0 +1,375 @@
+package subcore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/legenove/nano-server-sdk/redis_client"
+	"github.com/legenove/nano-server-sdk/servers"
+	"github.com/legenove/nano-server-sdk/stacktrace"
+	"google.golang.org/grpc/metadata"
+)
+
+// 死信 stream 的后缀
+const DeadLetterSuffix = ":dead"
+
+type options struct {
+	consumer      string
+	count         int64
+	block         time.Duration
+	concurrency   int
+	maxDeliveries int64
+	claimIdle     time.Duration
+	claimInterval time.Duration
+}
+
+type Option func(*options)
+
+// WithConsumer 消费者名称, 默认 hostname-pid
+func WithConsumer(name string) Option {
+	return func(o *options) {
+		o.consumer = name
+	}
+}
+
+// WithCount 每次读取的消息数量, 默认 10
+func WithCount(n int64) Option {
+	return func(o *options) {
+		o.count = n
+	}
+}
+
+// WithBlock 无消息时阻塞等待的时间, 默认 5s
+func WithBlock(d time.Duration) Option {
+	return func(o *options) {
+		o.block = d
+	}
+}
+
+// WithConcurrency 同时处理的消息数量, 默认 1
+func WithConcurrency(n int) Option {
+	return func(o *options) {
+		o.concurrency = n
+	}
+}
+
+// WithMaxDeliveries 最大投递次数, 超过后移入死信 stream, 默认 5
+func WithMaxDeliveries(n int64) Option {
+	return func(o *options) {
+		o.maxDeliveries = n
+	}
+}
+
+// WithClaim 认领空闲超过 idle 的待确认消息, 每 interval 检查一次, 默认 1min 与 30s
+// 处理中的消息每 idle/3 刷新一次空闲时间, 处理时间超过 idle 也不会被其他消费者认领
+func WithClaim(idle, interval time.Duration) Option {
+	return func(o *options) {
+		o.claimIdle = idle
+		o.claimInterval = interval
+	}
+}
+
+// Worker 消费已注册 stream 的消费组
+// 处理成功后 ack, 失败的消息保留在待确认列表中，空闲超过 claimIdle 后重新认领处理
+// 每次操作都从 redis_client 获取客户端, 配置热更新后使用新的客户端
+type Worker struct {
+	router string
+	group  string
+	opt    options
+}
+
+func NewWorker(routerName, group string, opts ...Option) *Worker {
+	host, _ := os.Hostname()
+	opt := options{
+		consumer:      fmt.Sprintf("%s-%d", host, os.Getpid()),
+		count:         10,
+		block:         5 * time.Second,
+		concurrency:   1,
+		maxDeliveries: 5,
+		claimIdle:     time.Minute,
+		claimInterval: 30 * time.Second,
+	}
+	for _, o := range opts {
+		o(&opt)
+	}
+	return &Worker{router: routerName, group: group, opt: opt}
+}
+
+// Run 阻塞运行直到 ctx 结束
+func (w *Worker) Run(ctx context.Context) error {
+	handlers := getHandlers()
+	if len(handlers) == 0 {
+		return fmt.Errorf("subcore: no handler registered")
+	}
+	if w.opt.claimIdle <= 0 || w.opt.claimInterval <= 0 {
+		return fmt.Errorf("subcore: claim idle and interval must be positive")
+	}
+	client, err := redis_client.GetRedisClient(w.router)
+	if err != nil {
+		return err
+	}
+	streams := make([]string, 0, len(handlers)*2)
+	for stream := range handlers {
+		err := client.XGroupCreateMkStream(stream, w.group, "0").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return err
+		}
+		streams = append(streams, stream)
+	}
+	for range handlers {
+		streams = append(streams, ">")
+	}
+
+	jobs := make(chan *Message)
+	var wg sync.WaitGroup
+	for i := 0; i < w.opt.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range jobs {
+				w.handle(ctx, handlers[msg.Stream], msg)
+			}
+		}()
+	}
+	// claimLoop 也会写入 jobs, 需要在它退出后才能关闭 jobs
+	claimDone := make(chan struct{})
+	go func() {
+		defer close(claimDone)
+		w.claimLoop(ctx, handlers, jobs)
+	}()
+
+	defer func() {
+		<-claimDone
+		close(jobs)
+		wg.Wait()
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+		client, err := redis_client.GetRedisClient(w.router)
+		if err != nil {
+			subLog(ctx, nil, "read_error", err.Error(), 0)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		res, err := client.XReadGroup(&redis.XReadGroupArgs{
+			Group:    w.group,
+			Consumer: w.opt.consumer,
+			Streams:  streams,
+			Count:    w.opt.count,
+			Block:    w.opt.block,
+		}).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			subLog(ctx, nil, "read_error", err.Error(), 0)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		for _, s := range res {
+			for _, m := range s.Messages {
+				select {
+				case jobs <- newMessage(s.Stream, m, 1):
+				case <-ctx.Done():
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// claimLoop 认领死掉的消费者遗留的消息, 超过最大投递次数的消息移入死信
+func (w *Worker) claimLoop(ctx context.Context, handlers map[string]Handler, jobs chan<- *Message) {
+	ticker := time.NewTicker(w.opt.claimInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		client, err := redis_client.GetRedisClient(w.router)
+		if err != nil {
+			continue
+		}
+		for stream := range handlers {
+			if !w.claim(ctx, client, stream, jobs) {
+				return
+			}
+		}
+	}
+}
+
+// claimPageSize 每次查询待确认列表的数量
+const claimPageSize = 100
+
+// claim 分页遍历待确认列表, 认领空闲超时的消息, ctx 结束时返回 false
+func (w *Worker) claim(ctx context.Context, client *redis.Client, stream string, jobs chan<- *Message) bool {
+	start := "-"
+	for {
+		pending, err := client.XPendingExt(&redis.XPendingExtArgs{
+			Stream: stream,
+			Group:  w.group,
+			Start:  start,
+			End:    "+",
+			Count:  claimPageSize,
+		}).Result()
+		if err != nil {
+			return true
+		}
+		for _, p := range pending {
+			if p.Idle < w.opt.claimIdle {
+				continue
+			}
+			msgs, err := client.XClaim(&redis.XClaimArgs{
+				Stream:   stream,
+				Group:    w.group,
+				Consumer: w.opt.consumer,
+				MinIdle:  w.opt.claimIdle,
+				Messages: []string{p.Id},
+			}).Result()
+			if err != nil {
+				continue
+			}
+			for _, m := range msgs {
+				msg := newMessage(stream, m, p.RetryCount+1)
+				if msg.Deliveries > w.opt.maxDeliveries {
+					w.deadLetter(ctx, client, msg)
+					continue
+				}
+				select {
+				case jobs <- msg:
+				case <-ctx.Done():
+					return false
+				}
+			}
+		}
+		if len(pending) < claimPageSize {
+			return true
+		}
+		start = nextId(pending[len(pending)-1].Id)
+	}
+}
+
+// nextId stream 中紧接 id 之后的 id, 用于分页查询
+func nextId(id string) string {
+	i := strings.IndexByte(id, '-')
+	if i < 0 {
+		return id
+	}
+	seq, err := strconv.ParseUint(id[i+1:], 10, 64)
+	if err != nil {
+		return id
+	}
+	return id[:i+1] + strconv.FormatUint(seq+1, 10)
+}
+
+// keepClaimed 处理期间定期将消息认领给自己以刷新空闲时间, 不增加投递次数
+func (w *Worker) keepClaimed(ctx context.Context, msg *Message) {
+	ticker := time.NewTicker(w.opt.claimIdle / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		client, err := redis_client.GetRedisClient(w.router)
+		if err != nil {
+			continue
+		}
+		client.XClaimJustID(&redis.XClaimArgs{
+			Stream:   msg.Stream,
+			Group:    w.group,
+			Consumer: w.opt.consumer,
+			Messages: []string{msg.ID},
+		})
+	}
+}
+
+func (w *Worker) deadLetter(ctx context.Context, client *redis.Client, msg *Message) {
+	values := make(map[string]interface{}, len(msg.Values)+2)
+	for k, v := range msg.Values {
+		values[k] = v
+	}
+	values["dead_from_id"] = msg.ID
+	values["dead_deliveries"] = msg.Deliveries
+	err := client.XAdd(&redis.XAddArgs{Stream: msg.Stream + DeadLetterSuffix, Values: values}).Err()
+	if err == nil {
+		err = client.XAck(msg.Stream, w.group, msg.ID).Err()
+	}
+	reason := "moved to " + msg.Stream + DeadLetterSuffix
+	if err != nil {
+		reason = err.Error()
+	}
+	subLog(w.requestCtx(ctx, msg), msg, "dead_letter", reason, 0)
+}
+
+// requestCtx 为消息创建请求上下文, 请求 id 优先使用生产者传入的值
+func (w *Worker) requestCtx(ctx context.Context, msg *Message) context.Context {
+	requestId := msg.GetString(servers.SERVER_INCOME_REQUEST_ID)
+	if requestId == "" {
+		requestId = msg.ID
+	}
+	md := metadata.Pairs(servers.SERVER_INCOME_REQUEST_ID, requestId)
+	if v := msg.GetString(servers.SERVER_INCOME_SERVER_NAME); v != "" {
+		md.Set(servers.SERVER_INCOME_SERVER_NAME, v)
+	}
+	if v := msg.GetString(servers.SERVER_INCOME_SERVER_GROUP); v != "" {
+		md.Set(servers.SERVER_INCOME_SERVER_GROUP, v)
+	}
+	ctx = metadata.NewIncomingContext(ctx, md)
+	ctx = servers.AppendToRequestCtx(ctx, servers.SERVER_REQUEST_TYPE, servers.GetServerTypeValue(servers.REQUEST_TYPE_SUB))
+	return servers.InitContext(ctx, msg.Stream, msg)
+}
+
+func (w *Worker) handle(ctx context.Context, h Handler, msg *Message) {
+	start := time.Now()
+	ctx = w.requestCtx(ctx, msg)
+	kctx, stop := context.WithCancel(context.Background())
+	go w.keepClaimed(kctx, msg)
+	err := w.call(ctx, h, msg)
+	stop()
+	duration := time.Since(start)
+	if err != nil {
+		// 不 ack, 等待重新认领
+		subLog(ctx, msg, "failed", err.Error(), duration, stacktrace.ErrorFields(err)...)
+		return
+	}
+	client, err := redis_client.GetRedisClient(w.router)
+	if err == nil {
+		err = client.XAck(msg.Stream, w.group, msg.ID).Err()
+	}
+	if err != nil {
+		subLog(ctx, msg, "ack_error", err.Error(), duration)
+		return
+	}
+	subLog(ctx, msg, "success", "", duration)
+}
+
+func (w *Worker) call(ctx context.Context, h Handler, msg *Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = stacktrace.NewPanicError(r)
+		}
+	}()
+	return h(ctx, msg)
+}
@@ -0,0 +1,136 @@
+package subcore
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/legenove/nano-server-sdk/redis_client"
+	"github.com/legenove/nano-server-sdk/redis_client/redistest"
+)
+
+// 取消 ctx 时 claimLoop 正在认领并投递消息, Run 需要正常退出
+func TestRunStopWhileClaimPending(t *testing.T) {
+	redistest.Start(t, "sub_redis")
+	client, err := redis_client.GetRedisClient("sub_redis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := "sub_test_claim"
+	if err := client.XGroupCreateMkStream(stream, "g", "0").Err(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		client.XAdd(&redis.XAddArgs{Stream: stream, Values: map[string]interface{}{"i": i}})
+	}
+	// 其他消费者读取后不 ack, 消息留在待确认列表中
+	if err := client.XReadGroup(&redis.XReadGroupArgs{Group: "g", Consumer: "dead", Streams: []string{stream, ">"}}).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	var handled int32
+	// 处理失败的消息不 ack, 会被 claimLoop 不断重新认领
+	RegisterHandler(stream, func(ctx context.Context, msg *Message) error {
+		atomic.AddInt32(&handled, 1)
+		return errors.New("retry")
+	})
+	defer func() {
+		mu.Lock()
+		delete(handlerMapper, stream)
+		mu.Unlock()
+	}()
+
+	w := NewWorker("sub_redis", "g", WithConsumer("alive"), WithBlock(time.Millisecond),
+		WithClaim(time.Millisecond, time.Millisecond), WithMaxDeliveries(1<<30))
+	for i := 0; i < 20; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() {
+			done <- w.Run(ctx)
+		}()
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Run did not return after cancel")
+		}
+	}
+	if atomic.LoadInt32(&handled) == 0 {
+		t.Error("claimed messages were not handled")
+	}
+}
+
+func readByDead(t *testing.T, client *redis.Client, stream string, n int) {
+	t.Helper()
+	if err := client.XGroupCreateMkStream(stream, "g", "0").Err(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		client.XAdd(&redis.XAddArgs{Stream: stream, Values: map[string]interface{}{"i": i}})
+	}
+	if err := client.XReadGroup(&redis.XReadGroupArgs{Group: "g", Consumer: "dead", Streams: []string{stream, ">"}}).Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 待确认列表超过一页时全部认领
+func TestClaimPages(t *testing.T) {
+	redistest.Start(t, "sub_page_redis")
+	client, _ := redis_client.GetRedisClient("sub_page_redis")
+	const n = claimPageSize*2 + 10
+	readByDead(t, client, "sub_test_page", n)
+	time.Sleep(5 * time.Millisecond)
+
+	w := NewWorker("sub_page_redis", "g", WithConsumer("alive"), WithClaim(time.Millisecond, time.Second))
+	jobs := make(chan *Message, n)
+	w.claim(context.Background(), client, "sub_test_page", jobs)
+	if len(jobs) != n {
+		t.Errorf("all %d pending messages should be claimed, got %d", n, len(jobs))
+	}
+}
+
+// 处理时间超过 claimIdle 的消息不会被其他消费者认领
+func TestKeepClaimedWhileHandling(t *testing.T) {
+	redistest.Start(t, "sub_keep_redis")
+	client, _ := redis_client.GetRedisClient("sub_keep_redis")
+	const idle = 30 * time.Millisecond
+	stream := "sub_test_keep"
+	if err := client.XGroupCreateMkStream(stream, "g", "0").Err(); err != nil {
+		t.Fatal(err)
+	}
+	client.XAdd(&redis.XAddArgs{Stream: stream, Values: map[string]interface{}{"a": 1}})
+	res, err := client.XReadGroup(&redis.XReadGroupArgs{Group: "g", Consumer: "a", Streams: []string{stream, ">"}}).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewWorker("sub_keep_redis", "g", WithConsumer("a"), WithClaim(idle, idle))
+	b := NewWorker("sub_keep_redis", "g", WithConsumer("b"), WithClaim(idle, idle))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.handle(context.Background(), func(ctx context.Context, msg *Message) error {
+			time.Sleep(5 * idle)
+			return nil
+		}, newMessage(stream, res[0].Messages[0], 1))
+	}()
+	jobs := make(chan *Message, 4)
+	for i := 0; i < 4; i++ {
+		time.Sleep(idle)
+		b.claim(context.Background(), client, stream, jobs)
+	}
+	<-done
+	if len(jobs) != 0 {
+		t.Error("message being handled should not be claimed by another consumer")
+	}
+	if n := client.XPending(stream, "g").Val().Count; n != 0 {
+		t.Errorf("handled message should be acked, %d pending", n)
+	}
+}